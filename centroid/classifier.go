@@ -0,0 +1,154 @@
+// Package centroid implements nearest-centroid
+// (Rocchio) classification, which compares a
+// document to the mean document of each language.
+package centroid
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/unixpickle/whichlang/sparse"
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// A Weighting determines how token frequencies
+// are turned into document vectors.
+type Weighting int
+
+const (
+	// TF uses token frequencies as they are.
+	TF Weighting = iota
+
+	// TFIDF multiplies each frequency by the inverse
+	// document frequency of the token, so that tokens
+	// found in most documents count for less.
+	TFIDF
+)
+
+// ParseWeighting parses the name of a Weighting,
+// either "tf" or "tfidf".
+func ParseWeighting(name string) (Weighting, error) {
+	switch name {
+	case "tf":
+		return TF, nil
+	case "tfidf":
+		return TFIDF, nil
+	default:
+		return 0, errors.New("unknown weighting: " + name)
+	}
+}
+
+func (w Weighting) String() string {
+	switch w {
+	case TF:
+		return "tf"
+	case TFIDF:
+		return "tfidf"
+	default:
+		return "Weighting(" + strconv.Itoa(int(w)) + ")"
+	}
+}
+
+// A Classifier stores a centroid for each language
+// and classifies documents by their cosine
+// similarity to each centroid.
+type Classifier struct {
+	Weighting Weighting
+
+	Tokens []string
+	Langs  []string
+
+	// IDF stores the inverse document frequency of
+	// each token for TFIDF.
+	IDF []float64 `json:",omitempty"`
+
+	// Centroids stores a unit vector for each
+	// language, indexed like Langs.
+	Centroids []sparse.Vector
+
+	tokenIndicesOnce sync.Once
+	tokenIndices     map[string]int
+}
+
+func DecodeClassifier(d []byte) (*Classifier, error) {
+	var c Classifier
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Centroids) != len(c.Langs) {
+		return nil, errors.New("mismatched language count")
+	}
+	if c.Weighting == TFIDF && len(c.IDF) != len(c.Tokens) {
+		return nil, errors.New("mismatched token count")
+	}
+	for _, v := range c.Centroids {
+		if len(v.Indices) > 0 && v.Indices[len(v.Indices)-1] >= len(c.Tokens) {
+			return nil, errors.New("centroid index out of range")
+		}
+	}
+	return &c, nil
+}
+
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	similarities := c.Similarities(f)
+	var bestLang string
+	bestSimilarity := math.Inf(-1)
+	for _, lang := range c.Langs {
+		if s := similarities[lang]; s > bestSimilarity {
+			bestSimilarity = s
+			bestLang = lang
+		}
+	}
+	return bestLang
+}
+
+// Similarities returns the cosine similarity
+// between a document and each language's centroid.
+func (c *Classifier) Similarities(f tokens.Freqs) map[string]float64 {
+	vec := c.vector(f)
+	norm := vec.Norm()
+	res := map[string]float64{}
+	for i, lang := range c.Langs {
+		if norm == 0 {
+			res[lang] = 0
+		} else {
+			res[lang] = vec.Dot(c.Centroids[i]) / norm
+		}
+	}
+	return res
+}
+
+func (c *Classifier) Encode() []byte {
+	res, _ := json.Marshal(c)
+	return res
+}
+
+func (c *Classifier) Languages() []string {
+	return c.Langs
+}
+
+// vector computes the weighted vector for a
+// document.
+func (c *Classifier) vector(f tokens.Freqs) sparse.Vector {
+	c.tokenIndicesOnce.Do(func() {
+		c.tokenIndices = map[string]int{}
+		for i, token := range c.Tokens {
+			c.tokenIndices[token] = i
+		}
+	})
+	return weightVector(sparse.FreqsVector(c.tokenIndices, f), c.IDF)
+}
+
+// weightVector multiplies the components of v by
+// their IDFs in place, if idf is non-nil.
+func weightVector(v sparse.Vector, idf []float64) sparse.Vector {
+	if idf != nil {
+		for i, idx := range v.Indices {
+			v.Values[i] *= idf[idx]
+		}
+	}
+	return v
+}
@@ -0,0 +1,67 @@
+package centroid
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func TestWeighting(t *testing.T) {
+	// Every sample has the token "common", and only
+	// one sample has the token "b".
+	data := map[string][]tokens.Freqs{
+		"A": {tokens.Counts{"common": 4, "a": 1}.Freqs()},
+		"B": {tokens.Counts{"common": 1, "b": 1}.Freqs()},
+	}
+	for i := 0; i < 8; i++ {
+		data["C"] = append(data["C"], tokens.Counts{"common": 1, "c": 4}.Freqs())
+	}
+	query := tokens.Counts{"common": 4, "b": 1}.Freqs()
+
+	// With TF, the query looks like A because of how
+	// often "common" appears.
+	// With TFIDF, the rare token "b" matters more.
+	expected := map[Weighting]string{TF: "A", TFIDF: "B"}
+	for weighting, lang := range expected {
+		p := &TrainerParams{Weighting: weighting}
+		c, err := DecodeClassifier(TrainParams(data, p).Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := c.Classify(query); actual != lang {
+			t.Errorf("%s: expected %s but got %s", weighting, lang, actual)
+		}
+		if weighting == TF {
+			if c.IDF != nil {
+				t.Error("unexpected IDF for TF")
+			}
+			continue
+		}
+		idf := map[string]float64{}
+		for i, token := range c.Tokens {
+			idf[token] = c.IDF[i]
+		}
+		if idf["common"] != 1 {
+			t.Errorf("expected IDF 1 for common token but got %f", idf["common"])
+		}
+		if idf["b"] <= idf["c"] || idf["c"] <= idf["common"] {
+			t.Errorf("IDF does not decrease with document frequency: %v", idf)
+		}
+	}
+}
+
+func TestShrinkage(t *testing.T) {
+	data := map[string][]tokens.Freqs{
+		"A": {{"x": 1}},
+		"B": {{"y": 1}},
+	}
+	c := TrainParams(data, &TrainerParams{Weighting: TF, Shrinkage: 1})
+	similarities := c.Similarities(tokens.Freqs{"x": 1})
+	if math.Abs(similarities["A"]-similarities["B"]) > 1e-8 {
+		t.Errorf("fully shrunken centroids differ: %v", similarities)
+	}
+	if expected := 1 / math.Sqrt(2); math.Abs(similarities["A"]-expected) > 1e-8 {
+		t.Errorf("expected similarity %f but got %f", expected, similarities["A"])
+	}
+}
@@ -0,0 +1,157 @@
+package centroid
+
+import (
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/unixpickle/whichlang/sparse"
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// These environment variables specify
+// various parameters for the trainer.
+const (
+	// You may set this to "tf" or "tfidf".
+	// The default is "tfidf".
+	WeightingEnvVar = "CENTROID_WEIGHTING"
+
+	// A number from 0 to 1 which moves every
+	// centroid toward the centroid of all the
+	// samples.
+	// This helps languages with few samples, whose
+	// centroids are noisy.
+	// The default is 0.
+	ShrinkageEnvVar = "CENTROID_SHRINKAGE"
+)
+
+// TrainerParams specifies parameters for the
+// nearest-centroid trainer.
+type TrainerParams struct {
+	Weighting Weighting
+	Shrinkage float64
+}
+
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := &TrainerParams{Weighting: TFIDF}
+	if val := os.Getenv(WeightingEnvVar); val != "" {
+		w, err := ParseWeighting(val)
+		if err != nil {
+			return nil, err
+		}
+		res.Weighting = w
+	}
+	if val := os.Getenv(ShrinkageEnvVar); val != "" {
+		shrinkage, err := strconv.ParseFloat(val, 64)
+		if err != nil || shrinkage < 0 || shrinkage > 1 {
+			return nil, errors.New("invalid shrinkage: " + val)
+		}
+		res.Shrinkage = shrinkage
+	}
+	return res, nil
+}
+
+// Train trains a Classifier using TrainerParams
+// from EnvTrainerParams.
+func Train(data map[string][]tokens.Freqs) *Classifier {
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(data, params)
+}
+
+// TrainParams computes the centroid of each
+// language's samples, after scaling every sample
+// vector to unit length.
+func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
+	res := &Classifier{Weighting: p.Weighting}
+	tokenSet := map[string]bool{}
+	for lang, samples := range data {
+		res.Langs = append(res.Langs, lang)
+		for _, sample := range samples {
+			for token := range sample {
+				tokenSet[token] = true
+			}
+		}
+	}
+	for token := range tokenSet {
+		res.Tokens = append(res.Tokens, token)
+	}
+	sort.Strings(res.Langs)
+	sort.Strings(res.Tokens)
+
+	tokenIndices := map[string]int{}
+	for i, token := range res.Tokens {
+		tokenIndices[token] = i
+	}
+	vecs := make([][]sparse.Vector, len(res.Langs))
+	docFreqs := make([]float64, len(res.Tokens))
+	var numDocs int
+	for i, lang := range res.Langs {
+		for _, sample := range data[lang] {
+			vec := sparse.FreqsVector(tokenIndices, sample)
+			for _, idx := range vec.Indices {
+				docFreqs[idx]++
+			}
+			vecs[i] = append(vecs[i], vec)
+			numDocs++
+		}
+	}
+
+	if p.Weighting == TFIDF {
+		// Smoothed IDF, which is positive even for
+		// tokens found in every document.
+		res.IDF = make([]float64, len(res.Tokens))
+		for i, df := range docFreqs {
+			res.IDF[i] = math.Log(float64(numDocs+1)/(df+1)) + 1
+		}
+	}
+
+	centroids := make([][]float64, len(res.Langs))
+	global := make([]float64, len(res.Tokens))
+	for i, langVecs := range vecs {
+		centroids[i] = make([]float64, len(res.Tokens))
+		for _, vec := range langVecs {
+			vec = weightVector(vec, res.IDF)
+			norm := vec.Norm()
+			if norm == 0 {
+				continue
+			}
+			for j, idx := range vec.Indices {
+				centroids[i][idx] += vec.Values[j] / norm
+				global[idx] += vec.Values[j] / norm
+			}
+		}
+		if len(langVecs) > 0 {
+			scale := 1 / float64(len(langVecs))
+			for j := range centroids[i] {
+				centroids[i][j] *= scale
+			}
+		}
+	}
+	if numDocs > 0 {
+		for j := range global {
+			global[j] /= float64(numDocs)
+		}
+	}
+
+	for _, centroid := range centroids {
+		for j, x := range centroid {
+			centroid[j] = (1-p.Shrinkage)*x + p.Shrinkage*global[j]
+		}
+		vec := sparse.NewVector(centroid)
+		if norm := vec.Norm(); norm != 0 {
+			vec.Scale(1 / norm)
+		}
+		res.Centroids = append(res.Centroids, vec)
+	}
+
+	return res
+}
@@ -0,0 +1,241 @@
+// Package charlm classifies text with a character
+// n-gram language model for each language.
+//
+// Unlike the other classifiers, these models work
+// best on raw text rather than token frequencies,
+// which lets them do well on very short inputs.
+package charlm
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"unicode/utf8"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// A Classifier picks the language whose model gives
+// a document the highest likelihood.
+type Classifier struct {
+	// Order is the longest n-gram in the models.
+	Order int
+
+	Langs  []string
+	Models []*Model
+
+	// alphabetSize is one more than the number of
+	// characters seen in training, leaving room for
+	// unseen characters.
+	alphabetSize float64
+}
+
+// NewClassifier creates a Classifier with no
+// languages, which can be trained with Learn.
+func NewClassifier(order int) *Classifier {
+	return &Classifier{Order: order, alphabetSize: 1}
+}
+
+func DecodeClassifier(d []byte) (*Classifier, error) {
+	var c Classifier
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, err
+	}
+	if c.Order < 1 {
+		return nil, errors.New("invalid order")
+	}
+	if len(c.Models) != len(c.Langs) {
+		return nil, errors.New("mismatched language count")
+	}
+	for _, m := range c.Models {
+		if m == nil || m.Counts == nil {
+			return nil, errors.New("missing model")
+		}
+		m.index()
+	}
+	c.countAlphabet()
+	return &c, nil
+}
+
+// Learn adds a document to the model for a
+// language, counting each n-gram weight times.
+// The language may be one the classifier has not
+// seen before.
+func (c *Classifier) Learn(lang, text string, weight float64) {
+	var m *Model
+	for i, l := range c.Langs {
+		if l == lang {
+			m = c.Models[i]
+		}
+	}
+	if m == nil {
+		m = newModel()
+		c.Langs = append(c.Langs, lang)
+		c.Models = append(c.Models, m)
+	}
+	var history []rune
+	for _, r := range text {
+		history = appendHistory(history, r, c.Order)
+		if c.unseen(r) {
+			c.alphabetSize++
+		}
+		m.add(history, weight)
+	}
+}
+
+// Prune removes n-grams (other than single
+// characters) which were counted fewer than
+// minCount times, to make the classifier smaller.
+func (c *Classifier) Prune(minCount float64) {
+	for _, m := range c.Models {
+		m.prune(minCount)
+	}
+}
+
+// Classify classifies a document from its token
+// frequencies by scoring each token as a separate
+// piece of text.
+// This loses the characters between tokens, so
+// ClassifyText is more accurate when the text is
+// available.
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	logs := make([]float64, len(c.Langs))
+	for token, freq := range f {
+		if token == "" || freq == 0 {
+			continue
+		}
+		var history []rune
+		for _, r := range token {
+			history = appendHistory(history, r, c.Order)
+			for i, m := range c.Models {
+				logs[i] += freq * math.Log(m.prob(history, c.alphabetSize))
+			}
+		}
+	}
+	return c.best(logs)
+}
+
+// ClassifyText classifies a piece of raw text.
+func (c *Classifier) ClassifyText(text string) string {
+	s := c.NewScorer()
+	s.Write([]byte(text))
+	return s.Best()
+}
+
+func (c *Classifier) Encode() []byte {
+	res, _ := json.Marshal(c)
+	return res
+}
+
+func (c *Classifier) Languages() []string {
+	return c.Langs
+}
+
+// NewScorer creates a Scorer which classifies text
+// as it is written.
+func (c *Classifier) NewScorer() *Scorer {
+	return &Scorer{
+		classifier: c,
+		logs:       make([]float64, len(c.Langs)),
+	}
+}
+
+func (c *Classifier) best(logs []float64) string {
+	var bestLang string
+	bestLog := math.Inf(-1)
+	for i, lang := range c.Langs {
+		if logs[i] > bestLog {
+			bestLog = logs[i]
+			bestLang = lang
+		}
+	}
+	return bestLang
+}
+
+func (c *Classifier) unseen(r rune) bool {
+	for _, m := range c.Models {
+		if m.Counts[string(r)] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Classifier) countAlphabet() {
+	alphabet := map[string]bool{}
+	for _, m := range c.Models {
+		for ngram := range m.Counts {
+			if utf8.RuneCountInString(ngram) == 1 {
+				alphabet[ngram] = true
+			}
+		}
+	}
+	c.alphabetSize = float64(len(alphabet) + 1)
+}
+
+// A Scorer computes the log-likelihood of a piece
+// of text under each language's model, one chunk
+// at a time.
+//
+// A Scorer is an io.Writer, so text can be copied
+// into it from any source.
+type Scorer struct {
+	classifier *Classifier
+	history    []rune
+	logs       []float64
+
+	// partial stores the bytes of a character which
+	// was split between writes.
+	partial []byte
+}
+
+// Write adds text to the scored document.
+// It never returns an error.
+func (s *Scorer) Write(p []byte) (int, error) {
+	data := p
+	if len(s.partial) > 0 {
+		data = append(s.partial, p...)
+		s.partial = nil
+	}
+	c := s.classifier
+	for len(data) > 0 {
+		if !utf8.FullRune(data) {
+			s.partial = append([]byte{}, data...)
+			break
+		}
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+		s.history = appendHistory(s.history, r, c.Order)
+		for i, m := range c.Models {
+			s.logs[i] += math.Log(m.prob(s.history, c.alphabetSize))
+		}
+	}
+	return len(p), nil
+}
+
+// LogLikelihoods returns the natural log of the
+// probability of the text so far under each
+// language's model.
+func (s *Scorer) LogLikelihoods() map[string]float64 {
+	res := map[string]float64{}
+	for i, lang := range s.classifier.Langs {
+		res[lang] = s.logs[i]
+	}
+	return res
+}
+
+// Best returns the language whose model gives the
+// text so far the highest likelihood.
+func (s *Scorer) Best() string {
+	return s.classifier.best(s.logs)
+}
+
+// appendHistory adds a character to the end of a
+// history, keeping at most order characters.
+func appendHistory(history []rune, r rune, order int) []rune {
+	if len(history) == order {
+		copy(history, history[1:])
+		history = history[:order-1]
+	}
+	return append(history, r)
+}
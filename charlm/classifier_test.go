@@ -0,0 +1,90 @@
+package charlm
+
+import (
+	"math"
+	"testing"
+)
+
+var testDocs = map[string][]string{
+	"Go": {
+		"func main() {\n\tx := 3\n\tfmt.Println(x)\n}\n",
+		"func (s *Server) Start() error {\n\tif err := s.listen(); err != nil {\n\t\treturn err\n\t}\n}\n",
+	},
+	"Python": {
+		"def main():\n    x = 3\n    print(x)\n",
+		"class Server:\n    def start(self):\n        if not self.listen():\n            return None\n",
+	},
+}
+
+func trainTestClassifier() *Classifier {
+	c := NewClassifier(4)
+	for lang, docs := range testDocs {
+		for _, doc := range docs {
+			c.Learn(lang, doc, 1)
+		}
+	}
+	return c
+}
+
+func TestClassifyText(t *testing.T) {
+	c, err := DecodeClassifier(trainTestClassifier().Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := map[string]string{
+		"Go":     "if err != nil {",
+		"Python": "def stop(self):",
+	}
+	for lang, query := range queries {
+		if actual := c.ClassifyText(query); actual != lang {
+			t.Errorf("expected %s but got %s", lang, actual)
+		}
+	}
+}
+
+func TestScorerIncremental(t *testing.T) {
+	c := trainTestClassifier()
+	text := "func (s *Server) stop() {\n\t// é\n}\n"
+
+	whole := c.NewScorer()
+	whole.Write([]byte(text))
+	expected := whole.LogLikelihoods()
+
+	// Write one byte at a time, splitting the
+	// multi-byte character.
+	pieces := c.NewScorer()
+	for i := 0; i < len(text); i++ {
+		pieces.Write([]byte{text[i]})
+	}
+	actual := pieces.LogLikelihoods()
+
+	for lang, x := range expected {
+		if math.Abs(actual[lang]-x) > 1e-8 {
+			t.Errorf("%s: expected %f but got %f", lang, x, actual[lang])
+		}
+	}
+}
+
+func TestProbabilitiesSumToOne(t *testing.T) {
+	c := trainTestClassifier()
+	alphabet := map[rune]bool{}
+	for _, docs := range testDocs {
+		for _, doc := range docs {
+			for _, r := range doc {
+				alphabet[r] = true
+			}
+		}
+	}
+	history := []rune("err")
+	for _, m := range c.Models {
+		var sum float64
+		for r := range alphabet {
+			sum += m.prob(append(history, r), c.alphabetSize)
+		}
+		// The remaining mass belongs to unseen characters.
+		sum += m.prob(append(history, '☃'), c.alphabetSize)
+		if math.Abs(sum-1) > 1e-8 {
+			t.Errorf("probabilities sum to %f", sum)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package charlm
+
+// A Model is a character n-gram language model for
+// one language.
+//
+// Probabilities are smoothed with Witten-Bell
+// interpolation: each context mixes its observed
+// continuations with the probabilities from the
+// next shorter context, giving the shorter context
+// more weight when the longer one has been
+// followed by many different characters.
+type Model struct {
+	// Counts maps each n-gram, from one character to
+	// the classifier's Order, to the (possibly
+	// fractional) number of times it was seen.
+	Counts map[string]float64
+
+	// totals and types map each context to the total
+	// count and number of distinct characters which
+	// followed it.
+	totals map[string]float64
+	types  map[string]float64
+}
+
+func newModel() *Model {
+	return &Model{
+		Counts: map[string]float64{},
+		totals: map[string]float64{},
+		types:  map[string]float64{},
+	}
+}
+
+// add records every n-gram which ends with the last
+// character of history, weighting them by weight.
+func (m *Model) add(history []rune, weight float64) {
+	last := string(history[len(history)-1])
+	for start := len(history) - 1; start >= 0; start-- {
+		context := string(history[start : len(history)-1])
+		ngram := context + last
+		if m.Counts[ngram] == 0 {
+			m.types[context]++
+		}
+		m.Counts[ngram] += weight
+		m.totals[context] += weight
+	}
+}
+
+// prune removes n-grams longer than one character
+// with counts below minCount.
+func (m *Model) prune(minCount float64) {
+	for ngram, count := range m.Counts {
+		if count < minCount && len([]rune(ngram)) > 1 {
+			delete(m.Counts, ngram)
+		}
+	}
+	m.index()
+}
+
+// index recomputes the context statistics from
+// Counts.
+func (m *Model) index() {
+	m.totals = map[string]float64{}
+	m.types = map[string]float64{}
+	for ngram, count := range m.Counts {
+		runes := []rune(ngram)
+		context := string(runes[:len(runes)-1])
+		m.totals[context] += count
+		m.types[context]++
+	}
+}
+
+// prob computes the probability of the last
+// character of history given the characters before
+// it, starting from a uniform distribution over
+// alphabetSize characters.
+func (m *Model) prob(history []rune, alphabetSize float64) float64 {
+	last := string(history[len(history)-1])
+	res := 1 / alphabetSize
+	for start := len(history) - 1; start >= 0; start-- {
+		context := string(history[start : len(history)-1])
+		total := m.totals[context]
+		if total == 0 {
+			// Longer contexts have not been seen either.
+			break
+		}
+		types := m.types[context]
+		res = (m.Counts[context+last] + types*res) / (total + types)
+	}
+	return res
+}
@@ -0,0 +1,109 @@
+package charlm
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+const (
+	DefaultOrder          = 5
+	DefaultMinCount       = 2.0
+	DefaultDocumentLength = 100.0
+)
+
+// These environment variables specify
+// various parameters for the trainer.
+const (
+	// The length of the longest character n-gram.
+	OrderEnvVar = "CHARLM_ORDER"
+
+	// N-grams seen fewer times than this are
+	// dropped after training.
+	// Set it to 0 to keep every n-gram.
+	MinCountEnvVar = "CHARLM_MIN_COUNT"
+)
+
+// TrainerParams specifies parameters for the
+// language model trainer.
+type TrainerParams struct {
+	Order    int
+	MinCount float64
+}
+
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := &TrainerParams{
+		Order:    DefaultOrder,
+		MinCount: DefaultMinCount,
+	}
+	if val := os.Getenv(OrderEnvVar); val != "" {
+		order, err := strconv.Atoi(val)
+		if err != nil || order < 1 {
+			return nil, errors.New("invalid order: " + val)
+		}
+		res.Order = order
+	}
+	if val := os.Getenv(MinCountEnvVar); val != "" {
+		minCount, err := strconv.ParseFloat(val, 64)
+		if err != nil || minCount < 0 {
+			return nil, errors.New("invalid minimum count: " + val)
+		}
+		res.MinCount = minCount
+	}
+	return res, nil
+}
+
+// Train trains a Classifier using TrainerParams
+// from EnvTrainerParams.
+func Train(data map[string][]tokens.Freqs) *Classifier {
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(data, params)
+}
+
+// TrainParams trains a Classifier on token
+// frequencies, treating each token as a separate
+// piece of text which appears as often as it would
+// in a document of DefaultDocumentLength tokens.
+//
+// Use TrainText when the raw text is available.
+func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
+	res := NewClassifier(p.Order)
+	for lang, samples := range data {
+		for _, sample := range samples {
+			for token, freq := range sample {
+				if token != "" && freq != 0 {
+					res.Learn(lang, token, freq*DefaultDocumentLength)
+				}
+			}
+		}
+	}
+	res.Prune(p.MinCount)
+	return res
+}
+
+// TrainText trains a Classifier on every document
+// from a tokens.TextReader.
+func TrainText(r tokens.TextReader, p *TrainerParams) (*Classifier, error) {
+	res := NewClassifier(p.Order)
+	for {
+		lang, text, err := r.ReadText()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		res.Learn(lang, text, 1)
+	}
+	res.Prune(p.MinCount)
+	return res, nil
+}
@@ -6,6 +6,9 @@ import (
 	"os"
 
 	"github.com/unixpickle/whichlang"
+	"github.com/unixpickle/whichlang/knn"
+	"github.com/unixpickle/whichlang/logreg"
+	"github.com/unixpickle/whichlang/naivebayes"
 	"github.com/unixpickle/whichlang/tokens"
 )
 
@@ -41,6 +44,39 @@ func main() {
 
 	counts := tokens.CountTokens(string(contents))
 	freqs := counts.Freqs()
-	language := classifier.Classify(freqs)
+	var language string
+	if textClassifier, ok := classifier.(whichlang.TextClassifier); ok {
+		language = textClassifier.ClassifyText(string(contents))
+	} else {
+		language = classifier.Classify(freqs)
+	}
 	fmt.Println("Classification:", language)
+
+	if knnClassifier, ok := classifier.(*knn.Classifier); ok {
+		fmt.Println("Nearest neighbors:")
+		for _, n := range knnClassifier.Neighbors(freqs) {
+			source := n.Source
+			if source == "" {
+				source = "(unknown source)"
+			}
+			fmt.Printf(" %s  correlation=%f distance=%f  %s\n", n.Language,
+				n.Correlation, n.Distance, source)
+		}
+	}
+
+	if bayesClassifier, ok := classifier.(*naivebayes.Classifier); ok {
+		fmt.Println("Log probabilities:")
+		logProbs := bayesClassifier.LogProbabilities(freqs)
+		for _, lang := range bayesClassifier.Languages() {
+			fmt.Printf(" %s  %f\n", lang, logProbs[lang])
+		}
+	}
+
+	if logregClassifier, ok := classifier.(*logreg.Classifier); ok {
+		fmt.Println("Probabilities:")
+		langs, probs := logregClassifier.Ranked(freqs)
+		for i, lang := range langs {
+			fmt.Printf(" %s  %f\n", lang, probs[i])
+		}
+	}
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/whichlang/idtree"
+)
+
+func main() {
+	if len(os.Args) != 3 || (os.Args[1] != "dot" && os.Args[1] != "rules") {
+		fmt.Fprintln(os.Stderr, "Usage: idtree-export <dot | rules> <classifier.json>")
+		os.Exit(1)
+	}
+
+	data, err := ioutil.ReadFile(os.Args[2])
+	if err != nil {
+		die(err)
+	}
+
+	classifier, err := idtree.DecodeClassifier(data)
+	if err != nil {
+		die(err)
+	}
+
+	if os.Args[1] == "dot" {
+		fmt.Print(classifier.Graphviz())
+	} else {
+		for _, rule := range classifier.Rules() {
+			fmt.Println(rule)
+		}
+	}
+}
+
+func die(e error) {
+	fmt.Fprintln(os.Stderr, e)
+	os.Exit(1)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/unixpickle/whichlang/knn"
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func main() {
+	if len(os.Args) != 5 {
+		dieUsage()
+	}
+
+	var edit, condense bool
+	switch os.Args[1] {
+	case "edit":
+		edit = true
+	case "condense":
+		condense = true
+	case "edit+condense":
+		edit = true
+		condense = true
+	default:
+		dieUsage()
+	}
+
+	data, err := ioutil.ReadFile(os.Args[2])
+	if err != nil {
+		die(err)
+	}
+	classifier, err := knn.DecodeClassifier(data)
+	if err != nil {
+		die(err)
+	}
+
+	validation, err := tokens.ReadSampleCounts(os.Args[4])
+	if err != nil {
+		die(err)
+	}
+
+	reduced := classifier
+	if edit {
+		reduced = reduced.Edited()
+	}
+	if condense {
+		reduced = reduced.Condensed()
+	}
+
+	oldScore := validationScore(classifier, validation)
+	newScore := validationScore(reduced, validation)
+	fmt.Printf("Samples: %d -> %d\n", len(classifier.Samples), len(reduced.Samples))
+	fmt.Printf("Neighbors: %d -> %d\n", classifier.NeighborCount, reduced.NeighborCount)
+	fmt.Printf("Validation success rate: %0.2f%% -> %0.2f%% (%+0.2f%%)\n", 100*oldScore,
+		100*newScore, 100*(newScore-oldScore))
+
+	if err := ioutil.WriteFile(os.Args[3], reduced.Encode(), 0755); err != nil {
+		die(err)
+	}
+}
+
+func validationScore(c *knn.Classifier, samples tokens.SampleCounts) float64 {
+	var correct, total int
+	for lang, langSamples := range samples {
+		for _, sample := range langSamples {
+			if c.Classify(sample.Freqs()) == lang {
+				correct++
+			}
+			total++
+		}
+	}
+	return float64(correct) / float64(total)
+}
+
+func dieUsage() {
+	fmt.Fprintln(os.Stderr, "Usage: knn-reduce <edit | condense | edit+condense> <knn_in.json>"+
+		" <knn_out.json> <validation-dir>")
+	os.Exit(1)
+}
+
+func die(e error) {
+	fmt.Fprintln(os.Stderr, e)
+	os.Exit(1)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 
@@ -33,13 +34,13 @@ func main() {
 		os.Exit(1)
 	}
 
-	samples, err := tokens.ReadSampleCounts(os.Args[3])
+	challenges, err := readChallenges(os.Args[3], classifier)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Failed to read samples:", err)
 		os.Exit(1)
 	}
 
-	rating := Rate(classifier, samples)
+	rating := Rate(classifier, challenges)
 
 	fmt.Printf("Success rate: %d/%d or %0.2f%%\n", rating.Correct, rating.Total,
 		100*rating.Frac())
@@ -54,3 +55,31 @@ func main() {
 			rating.Correct, rating.Total, 100*rating.Frac())
 	}
 }
+
+// readChallenges reads a Challenge for every file
+// in a sample directory.
+// Text classifiers are given the raw text, while
+// other classifiers are given token frequencies.
+func readChallenges(dir string, c whichlang.Classifier) ([]Challenge, error) {
+	reader, err := tokens.NewDirSampleReader(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	_, useText := c.(whichlang.TextClassifier)
+	var res []Challenge
+	for {
+		lang, text, err := reader.ReadText()
+		if err == io.EOF {
+			return res, nil
+		} else if err != nil {
+			return nil, err
+		}
+		challenge := Challenge{Language: lang}
+		if useText {
+			challenge.Text = text
+		} else {
+			challenge.Sample = tokens.CountTokens(text).Freqs()
+		}
+		res = append(res, challenge)
+	}
+}
@@ -8,9 +8,13 @@ import (
 	"github.com/unixpickle/whichlang/tokens"
 )
 
+// A Challenge is a sample to classify.
+// Text is only set for whichlang.TextClassifiers,
+// which classify it instead of Sample.
 type Challenge struct {
 	Language string
 	Sample   tokens.Freqs
+	Text     string
 }
 
 type Result struct {
@@ -18,7 +22,7 @@ type Result struct {
 	Correct  bool
 }
 
-func Rate(c whichlang.Classifier, s map[string][]tokens.Counts) *OverallRating {
+func Rate(c whichlang.Classifier, challenges []Challenge) *OverallRating {
 	var wg sync.WaitGroup
 	challengeChan := make(chan Challenge, 0)
 	resultChan := make(chan Result, 0)
@@ -28,7 +32,13 @@ func Rate(c whichlang.Classifier, s map[string][]tokens.Counts) *OverallRating {
 		go func() {
 			defer wg.Done()
 			for challenge := range challengeChan {
-				correct := (c.Classify(challenge.Sample) == challenge.Language)
+				var lang string
+				if textClassifier, ok := c.(whichlang.TextClassifier); ok {
+					lang = textClassifier.ClassifyText(challenge.Text)
+				} else {
+					lang = c.Classify(challenge.Sample)
+				}
+				correct := (lang == challenge.Language)
 				resultChan <- Result{
 					Language: challenge.Language,
 					Correct:  correct,
@@ -38,10 +48,8 @@ func Rate(c whichlang.Classifier, s map[string][]tokens.Counts) *OverallRating {
 	}
 
 	go func() {
-		for lang, langSamples := range s {
-			for _, sample := range langSamples {
-				challengeChan <- Challenge{lang, sample.Freqs()}
-			}
+		for _, challenge := range challenges {
+			challengeChan <- challenge
 		}
 		close(challengeChan)
 	}()
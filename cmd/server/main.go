@@ -26,9 +26,13 @@ func main() {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		counts := tokens.CountTokens(string(contents))
-		freqs := counts.Freqs()
-		lang := classifier.Classify(freqs)
+		var lang string
+		if textClassifier, ok := classifier.(whichlang.TextClassifier); ok {
+			lang = textClassifier.ClassifyText(string(contents))
+		} else {
+			counts := tokens.CountTokens(string(contents))
+			lang = classifier.Classify(counts.Freqs())
+		}
 		jsonObj := map[string]interface{}{"lang": lang}
 		jsonData, _ := json.Marshal(jsonObj)
 		w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/unixpickle/whichlang/svm"
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func main() {
+	if len(os.Args) != 5 {
+		fmt.Fprintln(os.Stderr, "Usage: svm-reduce <budget> <svm_in.json> <svm_out.json>"+
+			" <validation-dir>")
+		os.Exit(1)
+	}
+
+	budget, err := strconv.Atoi(os.Args[1])
+	if err != nil || budget < 1 {
+		die(fmt.Errorf("invalid budget: %s", os.Args[1]))
+	}
+
+	data, err := ioutil.ReadFile(os.Args[2])
+	if err != nil {
+		die(err)
+	}
+	classifier, err := svm.DecodeClassifier(data)
+	if err != nil {
+		die(err)
+	}
+
+	validation, err := tokens.ReadSampleCounts(os.Args[4])
+	if err != nil {
+		die(err)
+	}
+
+	reduced := classifier.Reduce(budget)
+
+	oldScore := validationScore(classifier, validation)
+	newScore := validationScore(reduced, validation)
+	fmt.Printf("Support vectors: %d -> %d\n", len(classifier.SampleVectors),
+		len(reduced.SampleVectors))
+	fmt.Printf("Validation success rate: %0.2f%% -> %0.2f%% (%+0.2f%%)\n", 100*oldScore,
+		100*newScore, 100*(newScore-oldScore))
+
+	if err := ioutil.WriteFile(os.Args[3], reduced.Encode(), 0755); err != nil {
+		die(err)
+	}
+}
+
+func validationScore(c *svm.Classifier, samples tokens.SampleCounts) float64 {
+	var correct, total int
+	for lang, langSamples := range samples {
+		for _, sample := range langSamples {
+			if c.Classify(sample.Freqs()) == lang {
+				correct++
+			}
+			total++
+		}
+	}
+	return float64(correct) / float64(total)
+}
+
+func die(e error) {
+	fmt.Fprintln(os.Stderr, e)
+	os.Exit(1)
+}
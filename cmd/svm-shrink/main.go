@@ -6,7 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 
-	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/whichlang/sparse"
 	"github.com/unixpickle/whichlang/svm"
 )
 
@@ -28,23 +28,21 @@ func main() {
 		die(errors.New("can only shrink linear classifiers"))
 	}
 
-	langs := classifier.Languages()
-
 	newClassifier := &svm.Classifier{
-		Keywords:      classifier.Keywords,
-		Kernel:        classifier.Kernel,
-		SampleVectors: make([]linalg.Vector, len(langs)),
-		Classifiers:   map[string]svm.BinaryClassifier{},
+		Keywords:   classifier.Keywords,
+		Kernel:     classifier.Kernel,
+		Multiclass: classifier.Multiclass,
 	}
 
-	for i, lang := range langs {
-		newClassifier.SampleVectors[i] = combineLanguageVecs(classifier, lang)
-		bc := svm.BinaryClassifier{
-			SupportVectors: []int{i},
-			Weights:        []float64{1},
-			Threshold:      classifier.Classifiers[lang].Threshold,
-		}
-		newClassifier.Classifiers[lang] = bc
+	if classifier.Classifiers != nil {
+		newClassifier.Classifiers = map[string]svm.BinaryClassifier{}
+	}
+	for lang, bc := range classifier.Classifiers {
+		newClassifier.Classifiers[lang] = shrinkBinary(classifier, newClassifier, bc)
+	}
+	for _, pair := range classifier.PairClassifiers {
+		pair.BinaryClassifier = shrinkBinary(classifier, newClassifier, pair.BinaryClassifier)
+		newClassifier.PairClassifiers = append(newClassifier.PairClassifiers, pair)
 	}
 
 	encoded := newClassifier.Encode()
@@ -53,13 +51,27 @@ func main() {
 	}
 }
 
-func combineLanguageVecs(c *svm.Classifier, lang string) linalg.Vector {
-	sum := make(linalg.Vector, len(c.Keywords))
-	bc := c.Classifiers[lang]
+// shrinkBinary combines the support vectors of a
+// binary classifier from c into a single vector,
+// which it adds to newC.
+func shrinkBinary(c, newC *svm.Classifier, bc svm.BinaryClassifier) svm.BinaryClassifier {
+	newC.SampleVectors = append(newC.SampleVectors, combineVecs(c, bc))
+	return svm.BinaryClassifier{
+		SupportVectors: []int{len(newC.SampleVectors) - 1},
+		Weights:        []float64{1},
+		Threshold:      bc.Threshold,
+	}
+}
+
+func combineVecs(c *svm.Classifier, bc svm.BinaryClassifier) sparse.Vector {
+	sum := make([]float64, len(c.Keywords))
 	for i, idx := range bc.SupportVectors {
-		sum.Add(c.SampleVectors[idx].Copy().Scale(bc.Weights[i]))
+		vec := c.SampleVectors[idx]
+		for j, component := range vec.Indices {
+			sum[component] += vec.Values[j] * bc.Weights[i]
+		}
 	}
-	return sum
+	return sparse.NewVector(sum)
 }
 
 func die(e error) {
@@ -9,21 +9,32 @@ import (
 	"time"
 
 	"github.com/unixpickle/whichlang"
+	"github.com/unixpickle/whichlang/neuralnet"
 	"github.com/unixpickle/whichlang/tokens"
 )
 
-const HelpColumnSize = 10
+const HelpColumnSize = 14
 
 func main() {
 	// Several machine learning algorithms depend on
 	// random starting positions.
 	rand.Seed(time.Now().UnixNano())
 
-	if len(os.Args) != 5 {
+	args := os.Args[1:]
+	var resumePath string
+	if len(args) > 0 && args[0] == "-resume" {
+		if len(args) < 2 {
+			dieUsage()
+		}
+		resumePath = args[1]
+		args = args[2:]
+	}
+
+	if len(args) != 4 {
 		dieUsage()
 	}
 
-	algorithm := os.Args[1]
+	algorithm := args[0]
 
 	trainer := whichlang.Trainers[algorithm]
 	if trainer == nil {
@@ -31,16 +42,49 @@ func main() {
 		dieUsage()
 	}
 
-	ubiquity, err := strconv.Atoi(os.Args[2])
+	ubiquity, err := strconv.Atoi(args[1])
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "Invalid ubiquity:", ubiquity, "(expected integer)")
 		os.Exit(1)
 	}
 
-	sampleDir := os.Args[3]
-	outputFile := os.Args[4]
+	sampleDir := args[2]
+	outputFile := args[3]
+
+	if streamTrainer := whichlang.StreamTrainers[algorithm]; streamTrainer != nil {
+		// These classifiers stream samples from disk, so
+		// there are no counts to prune.
+		fmt.Println("Training...")
+		classifier := trainStream(streamTrainer, sampleDir, resumePath)
+		fmt.Println("Saving...")
+		saveClassifier(classifier, outputFile)
+		return
+	}
+
+	if resumePath != "" {
+		resumable := whichlang.ResumableTrainers[algorithm]
+		if resumable == nil {
+			fmt.Fprintln(os.Stderr, "Resuming is not supported for", algorithm)
+			os.Exit(1)
+		}
+		trainer, err = resumable(resumePath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error resuming:", err)
+			os.Exit(1)
+		}
+	}
+
+	if textTrainer := whichlang.TextTrainers[algorithm]; textTrainer != nil {
+		// These classifiers are trained on the raw text
+		// rather than pruned tokens.
+		fmt.Println("Training...")
+		classifier := trainText(textTrainer, sampleDir)
+		fmt.Println("Saving...")
+		saveClassifier(classifier, outputFile)
+		return
+	}
 
-	counts, err := tokens.ReadSampleCounts(sampleDir)
+	counts, sources, err := tokens.ReadSampleSources(sampleDir)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -53,23 +97,69 @@ func main() {
 	fmt.Printf("Pruned %d/%d tokens (%d left).\n", (oldCount - newCount),
 		oldCount, newCount)
 
-	freqs := counts.SampleFreqs()
-
 	fmt.Println("Training...")
-	classifier := trainer(freqs)
+	var classifier whichlang.Classifier
+	if countTrainer := whichlang.CountTrainers[algorithm]; countTrainer != nil {
+		classifier, err = countTrainer(counts)
+	} else if sourceTrainer := whichlang.SourceTrainers[algorithm]; sourceTrainer != nil {
+		classifier, err = sourceTrainer(counts.SampleFreqs(), sources)
+	} else {
+		classifier = trainer(counts.SampleFreqs())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 
 	fmt.Println("Saving...")
-	data := classifier.Encode()
+	saveClassifier(classifier, outputFile)
+}
 
+func saveClassifier(classifier whichlang.Classifier, outputFile string) {
+	data := classifier.Encode()
 	if err := ioutil.WriteFile(outputFile, data, 0755); err != nil {
 		fmt.Fprintln(os.Stderr, "Error writing file:", err)
 		os.Exit(1)
 	}
 }
 
+// trainStream trains a classifier by streaming
+// the samples in a directory.
+func trainStream(t whichlang.StreamTrainer, sampleDir, resumePath string) whichlang.Classifier {
+	source := func() (tokens.SampleReader, error) {
+		return tokens.NewDirSampleReader(sampleDir, true)
+	}
+	classifier, err := t(source, resumePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return classifier
+}
+
+// trainText trains a classifier on the text of
+// every file in a directory.
+func trainText(t whichlang.TextTrainer, sampleDir string) whichlang.Classifier {
+	reader, err := tokens.NewDirSampleReader(sampleDir, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	classifier, err := t(reader)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return classifier
+}
+
 func dieUsage() {
-	fmt.Fprintln(os.Stderr, "Usage: trainer <algorithm> <ubiquity> <sample-dir> <output>\n\n"+
-		" (ubiquity specifies the number of files in which a\n  keyword should appear.)\n\n"+
+	fmt.Fprintln(os.Stderr, "Usage: trainer [-resume <checkpoint>] <algorithm> <ubiquity>"+
+		" <sample-dir> <output>\n\n"+
+		" (ubiquity specifies the number of files in which a\n  keyword should appear.)\n"+
+		" (-resume continues a neuralnet from a checkpoint saved\n  via "+
+		neuralnet.CheckpointEnvVar+", or a perceptron from a saved classifier.)\n"+
+		" (perceptron, charlm, ncd, and fasttext read samples as text and\n  ignore ubiquity.)\n\n"+
 		"Available algorithms:")
 	for _, name := range whichlang.ClassifierNames {
 		spaces := ""
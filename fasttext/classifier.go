@@ -0,0 +1,168 @@
+// Package fasttext implements a linear classifier
+// over averaged embeddings of hashed words and
+// character n-grams, in the style of fastText.
+package fasttext
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// A Classifier averages the embeddings of a
+// document's features and applies a softmax layer
+// to the result.
+type Classifier struct {
+	Langs []string
+
+	Dim        int
+	Buckets    int
+	WordNgrams NgramRange
+	CharNgrams NgramRange
+
+	// Rows lists the buckets which were used in
+	// training, in increasing order.
+	// Other buckets have zero embeddings.
+	Rows []int
+
+	// Embeddings stores Dim little-endian float32
+	// components for each entry in Rows.
+	Embeddings []byte
+
+	// OutputWeights stores Dim weights for each
+	// language, indexed like Langs.
+	OutputWeights [][]float64
+	OutputBiases  []float64
+
+	initOnce   sync.Once
+	rowIndices map[int]int
+	vectors    []float32
+}
+
+func DecodeClassifier(d []byte) (*Classifier, error) {
+	var c Classifier
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, err
+	}
+	if c.Dim < 1 || c.Buckets < 1 {
+		return nil, errors.New("invalid dimensions")
+	}
+	if len(c.Langs) == 0 {
+		return nil, errors.New("no languages")
+	}
+	if len(c.OutputWeights) != len(c.Langs) || len(c.OutputBiases) != len(c.Langs) {
+		return nil, errors.New("mismatched language count")
+	}
+	for _, w := range c.OutputWeights {
+		if len(w) != c.Dim {
+			return nil, errors.New("mismatched output dimension")
+		}
+	}
+	if len(c.Embeddings) != 4*c.Dim*len(c.Rows) {
+		return nil, errors.New("mismatched embedding count")
+	}
+	return &c, nil
+}
+
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	return c.best(c.featurizer().freqsFeatures(f))
+}
+
+// ClassifyText classifies raw text, which adds
+// word n-gram features that token frequencies
+// cannot provide.
+func (c *Classifier) ClassifyText(text string) string {
+	return c.best(c.featurizer().textFeatures(text))
+}
+
+// Probabilities returns the probability of each
+// language for a piece of raw text.
+func (c *Classifier) Probabilities(text string) map[string]float64 {
+	probs := c.probs(c.featurizer().textFeatures(text))
+	res := map[string]float64{}
+	for i, lang := range c.Langs {
+		res[lang] = probs[i]
+	}
+	return res
+}
+
+func (c *Classifier) Encode() []byte {
+	res, _ := json.Marshal(c)
+	return res
+}
+
+func (c *Classifier) Languages() []string {
+	return c.Langs
+}
+
+func (c *Classifier) featurizer() *featurizer {
+	return &featurizer{
+		buckets:    c.Buckets,
+		wordNgrams: c.WordNgrams,
+		charNgrams: c.CharNgrams,
+	}
+}
+
+func (c *Classifier) best(f *features) string {
+	probs := c.probs(f)
+	var bestIdx int
+	for i, p := range probs {
+		if p > probs[bestIdx] {
+			bestIdx = i
+		}
+	}
+	return c.Langs[bestIdx]
+}
+
+func (c *Classifier) probs(f *features) []float64 {
+	c.initOnce.Do(func() {
+		c.rowIndices = map[int]int{}
+		for i, row := range c.Rows {
+			c.rowIndices[row] = i
+		}
+		c.vectors = make([]float32, len(c.Embeddings)/4)
+		for i := range c.vectors {
+			bits := binary.LittleEndian.Uint32(c.Embeddings[i*4:])
+			c.vectors[i] = math.Float32frombits(bits)
+		}
+	})
+	hidden := make([]float64, c.Dim)
+	for i, bucket := range f.buckets {
+		row, ok := c.rowIndices[bucket]
+		if !ok {
+			continue
+		}
+		vec := c.vectors[row*c.Dim : (row+1)*c.Dim]
+		for j, x := range vec {
+			hidden[j] += f.weights[i] * float64(x)
+		}
+	}
+	return outputProbs(hidden, c.OutputWeights, c.OutputBiases)
+}
+
+// outputProbs applies the softmax layer to a
+// hidden vector.
+func outputProbs(hidden []float64, weights [][]float64, biases []float64) []float64 {
+	res := make([]float64, len(weights))
+	max := math.Inf(-1)
+	for i, w := range weights {
+		res[i] = biases[i]
+		for j, x := range hidden {
+			res[i] += w[j] * x
+		}
+		max = math.Max(max, res[i])
+	}
+	var sum float64
+	for i, x := range res {
+		res[i] = math.Exp(x - max)
+		sum += res[i]
+	}
+	for i := range res {
+		res[i] /= sum
+	}
+	return res
+}
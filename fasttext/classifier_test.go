@@ -0,0 +1,184 @@
+package fasttext
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func testParams() *TrainerParams {
+	return &TrainerParams{
+		Dim:          8,
+		Buckets:      1 << 12,
+		WordNgrams:   DefaultWordNgrams,
+		CharNgrams:   DefaultCharNgrams,
+		Epochs:       50,
+		LearningRate: DefaultLearningRate,
+		Seed:         1,
+	}
+}
+
+func TestParseNgramRange(t *testing.T) {
+	valid := map[string]NgramRange{
+		"3-5": {3, 5},
+		"2":   {2, 2},
+		"0":   {},
+		"0-0": {},
+	}
+	for s, expected := range valid {
+		if actual, err := ParseNgramRange(s); err != nil {
+			t.Errorf("%s: %s", s, err)
+		} else if actual != expected {
+			t.Errorf("%s: expected %v but got %v", s, expected, actual)
+		}
+	}
+	for _, s := range []string{"", "5-3", "0-2", "1-2-3", "a", "-1"} {
+		if _, err := ParseNgramRange(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestLearn(t *testing.T) {
+	trained, err := TrainText(testSamples(), testParams())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := DecodeClassifier(trained.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testSamples()
+	for i, text := range r.Texts {
+		if actual := c.ClassifyText(text); actual != r.Langs[i] {
+			t.Errorf("training sample %d: expected %s but got %s", i, r.Langs[i], actual)
+		}
+	}
+
+	queries := map[string]string{
+		"Go":     "if err := s.listen(); err != nil {",
+		"Python": "    def stop(self):\n        return None",
+	}
+	for lang, query := range queries {
+		if actual := c.ClassifyText(query); actual != lang {
+			t.Errorf("expected %s but got %s", lang, actual)
+		}
+		if p := c.Probabilities(query)[lang]; p < 0.5 {
+			t.Errorf("%s has probability %f", lang, p)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	p := testParams()
+	first, err := TrainText(testSamples(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := TrainText(testSamples(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Encode(), second.Encode()) {
+		t.Error("the same seed gave different classifiers")
+	}
+	p.Seed++
+	third, err := TrainText(testSamples(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first.Encode(), third.Encode()) {
+		t.Error("different seeds gave the same classifier")
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	for _, buckets := range []int{4, 1 << 12} {
+		p := testParams()
+		p.Buckets = buckets
+		trained, err := TrainText(testSamples(), p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := DecodeClassifier(trained.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Rows should list exactly the buckets of the
+		// training features, in order.
+		used := map[int]bool{}
+		r := testSamples()
+		for _, text := range r.Texts {
+			for _, bucket := range c.featurizer().textFeatures(text).buckets {
+				used[bucket] = true
+			}
+		}
+		if len(c.Rows) != len(used) {
+			t.Errorf("%d buckets: expected %d rows but got %d", buckets, len(used),
+				len(c.Rows))
+		}
+		for i, row := range c.Rows {
+			if row < 0 || row >= buckets || !used[row] {
+				t.Errorf("%d buckets: unexpected row %d", buckets, row)
+			}
+			if i > 0 && row <= c.Rows[i-1] {
+				t.Errorf("%d buckets: rows out of order: %v", buckets, c.Rows)
+				break
+			}
+		}
+
+		probs := c.Probabilities("func main() {}")
+		if sum := probs["Go"] + probs["Python"]; math.Abs(sum-1) > 1e-8 {
+			t.Errorf("%d buckets: probabilities sum to %f", buckets, sum)
+		}
+	}
+}
+
+func TestNoLanguages(t *testing.T) {
+	if _, err := TrainText(&tokens.SliceTextReader{}, testParams()); err == nil {
+		t.Error("expected error training without samples")
+	}
+	c := &Classifier{Dim: 1, Buckets: 1}
+	if _, err := DecodeClassifier(c.Encode()); err == nil {
+		t.Error("expected error decoding a classifier without languages")
+	}
+}
+
+func TestNoFeatures(t *testing.T) {
+	// Token frequencies have no word n-grams longer
+	// than one word.
+	p := testParams()
+	p.WordNgrams = NgramRange{Min: 2, Max: 3}
+	p.CharNgrams = NgramRange{}
+	data := map[string][]tokens.Freqs{
+		"Go": {tokens.Counts{"func": 1, "main": 1}.Freqs()},
+	}
+	if _, err := TrainParams(data, p); err == nil {
+		t.Error("expected error training on frequencies without features")
+	}
+
+	r := &tokens.SliceTextReader{
+		Langs: []string{"Go", "Python"},
+		Texts: []string{"func", "def"},
+	}
+	if _, err := TrainText(r, p); err == nil {
+		t.Error("expected error training on text without features")
+	}
+}
+
+func testSamples() *tokens.SliceTextReader {
+	return &tokens.SliceTextReader{
+		Langs: []string{"Go", "Python", "Go", "Python"},
+		Texts: []string{
+			"func main() {\n\tx := 3\n\tfmt.Println(x)\n}\n",
+			"def main():\n    x = 3\n    print(x)\n",
+			"func (s *Server) Start() error {\n\tif err := s.listen(); err != nil {\n" +
+				"\t\treturn err\n\t}\n\treturn nil\n}\n",
+			"class Server:\n    def start(self):\n        if not self.listen():\n" +
+				"            return None\n",
+		},
+	}
+}
@@ -0,0 +1,151 @@
+package fasttext
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// An NgramRange is an inclusive range of n-gram
+// lengths.
+// A range with a Max of 0 includes no n-grams.
+type NgramRange struct {
+	Min int
+	Max int
+}
+
+// ParseNgramRange parses a range such as "3-5", a
+// single length such as "2", or "0" for no n-grams.
+func ParseNgramRange(s string) (NgramRange, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) > 2 {
+		return NgramRange{}, errors.New("invalid n-gram range: " + s)
+	}
+	var nums []int
+	for _, part := range parts {
+		num, err := strconv.Atoi(part)
+		if err != nil || num < 0 {
+			return NgramRange{}, errors.New("invalid n-gram range: " + s)
+		}
+		nums = append(nums, num)
+	}
+	res := NgramRange{Min: nums[0], Max: nums[len(nums)-1]}
+	if res.Max == 0 {
+		return NgramRange{}, nil
+	} else if res.Min < 1 || res.Min > res.Max {
+		return NgramRange{}, errors.New("invalid n-gram range: " + s)
+	}
+	return res, nil
+}
+
+func (n NgramRange) String() string {
+	if n.Max == 0 {
+		return "0"
+	}
+	return strconv.Itoa(n.Min) + "-" + strconv.Itoa(n.Max)
+}
+
+// Feature type prefixes keep words, word n-grams,
+// and character n-grams with the same text from
+// always sharing a bucket.
+const (
+	wordPrefix     = "w"
+	wordGramPrefix = "g"
+	charGramPrefix = "c"
+)
+
+// A featurizer turns documents into weighted
+// hashed features.
+type featurizer struct {
+	buckets    int
+	wordNgrams NgramRange
+	charNgrams NgramRange
+}
+
+// features stores the buckets of a document's
+// features and their weights, which sum to 1.
+type features struct {
+	buckets []int
+	weights []float64
+}
+
+func (f *featurizer) freqsFeatures(freqs tokens.Freqs) *features {
+	// Sorting the tokens makes the order of the
+	// features, and therefore training, the same for
+	// a given seed.
+	var sorted []string
+	for token, freq := range freqs {
+		if token != "" && freq != 0 {
+			sorted = append(sorted, token)
+		}
+	}
+	sort.Strings(sorted)
+
+	res := &features{}
+	for _, token := range sorted {
+		freq := freqs[token]
+		if f.wordNgrams.Min == 1 {
+			res.add(f.hash(wordPrefix, token), freq)
+		}
+		if f.charNgrams.Max > 0 {
+			runes := []rune("<" + token + ">")
+			for n := f.charNgrams.Min; n <= f.charNgrams.Max; n++ {
+				for i := 0; i+n <= len(runes); i++ {
+					res.add(f.hash(charGramPrefix, string(runes[i:i+n])), freq)
+				}
+			}
+		}
+	}
+	res.normalize()
+	return res
+}
+
+// textFeatures computes the same features as
+// freqsFeatures, plus word n-grams longer than one
+// word, treating every run of non-space characters
+// as a word.
+func (f *featurizer) textFeatures(text string) *features {
+	res := f.freqsFeatures(tokens.CountTokens(text).Freqs())
+	words := strings.Fields(text)
+	for n := f.wordNgrams.Min; n <= f.wordNgrams.Max; n++ {
+		if n == 1 || n > len(words) {
+			continue
+		}
+		weight := 1 / float64(len(words)-n+1)
+		for i := 0; i+n <= len(words); i++ {
+			gram := strings.Join(words[i:i+n], " ")
+			res.add(f.hash(wordGramPrefix, gram), weight)
+		}
+	}
+	res.normalize()
+	return res
+}
+
+func (f *featurizer) hash(prefix, s string) int {
+	h := fnv.New32a()
+	h.Write([]byte(prefix))
+	h.Write([]byte(s))
+	return int(h.Sum32() % uint32(f.buckets))
+}
+
+func (f *features) add(bucket int, weight float64) {
+	f.buckets = append(f.buckets, bucket)
+	f.weights = append(f.weights, weight)
+}
+
+func (f *features) normalize() {
+	var sum float64
+	for _, w := range f.weights {
+		sum += w
+	}
+	if sum == 0 {
+		return
+	}
+	for i := range f.weights {
+		f.weights[i] /= sum
+	}
+}
@@ -0,0 +1,424 @@
+package fasttext
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+const (
+	DefaultDim          = 16
+	DefaultBuckets      = 1 << 18
+	DefaultEpochs       = 5
+	DefaultLearningRate = 0.5
+	DefaultChunkLines   = 8
+)
+
+var (
+	DefaultWordNgrams = NgramRange{Min: 1, Max: 2}
+	DefaultCharNgrams = NgramRange{Min: 3, Max: 5}
+)
+
+// These environment variables specify
+// various parameters for the trainer.
+const (
+	// Set this to "1" to get verbose logs.
+	VerboseEnvVar = "FASTTEXT_VERBOSE"
+
+	// The number of components in each embedding.
+	DimEnvVar = "FASTTEXT_DIM"
+
+	// The number of hash buckets shared by all of
+	// the features.
+	// Only buckets which are used in training are
+	// stored in the classifier.
+	BucketsEnvVar = "FASTTEXT_BUCKETS"
+
+	// The range of word n-gram lengths, such as
+	// "1-2".
+	// Word n-grams longer than one word are only
+	// available when training on raw text.
+	WordNgramsEnvVar = "FASTTEXT_WORD_NGRAMS"
+
+	// The range of character n-gram lengths within
+	// each token, such as "3-5", or "0" to disable
+	// them.
+	CharNgramsEnvVar = "FASTTEXT_CHAR_NGRAMS"
+
+	// The number of lines in each training sample
+	// when training on text, or 0 to train on whole
+	// documents.
+	// Short chunks teach the classifier to handle
+	// short snippets.
+	ChunkLinesEnvVar = "FASTTEXT_CHUNK_LINES"
+
+	// The number of passes over the samples.
+	EpochsEnvVar = "FASTTEXT_EPOCHS"
+
+	// The initial SGD step size, which decays
+	// linearly to zero over training.
+	LearningRateEnvVar = "FASTTEXT_LEARNING_RATE"
+
+	// The seed for initialization and sample order.
+	// By default, the current time is used.
+	SeedEnvVar = "FASTTEXT_SEED"
+)
+
+// TrainerParams specifies parameters for the
+// trainer.
+type TrainerParams struct {
+	Verbose bool
+
+	Dim        int
+	Buckets    int
+	WordNgrams NgramRange
+	CharNgrams NgramRange
+	ChunkLines int
+
+	Epochs       int
+	LearningRate float64
+	Seed         int64
+}
+
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := &TrainerParams{
+		Verbose:      os.Getenv(VerboseEnvVar) == "1",
+		Dim:          DefaultDim,
+		Buckets:      DefaultBuckets,
+		WordNgrams:   DefaultWordNgrams,
+		CharNgrams:   DefaultCharNgrams,
+		ChunkLines:   DefaultChunkLines,
+		Epochs:       DefaultEpochs,
+		LearningRate: DefaultLearningRate,
+		Seed:         time.Now().UnixNano(),
+	}
+	var err error
+	if res.Dim, err = envPositiveInt(DimEnvVar, res.Dim); err != nil {
+		return nil, err
+	}
+	if res.Buckets, err = envPositiveInt(BucketsEnvVar, res.Buckets); err != nil {
+		return nil, err
+	}
+	if res.Epochs, err = envPositiveInt(EpochsEnvVar, res.Epochs); err != nil {
+		return nil, err
+	}
+	if val := os.Getenv(ChunkLinesEnvVar); val != "" {
+		lines, err := strconv.Atoi(val)
+		if err != nil || lines < 0 {
+			return nil, errors.New("invalid chunk lines: " + val)
+		}
+		res.ChunkLines = lines
+	}
+	if val := os.Getenv(WordNgramsEnvVar); val != "" {
+		if res.WordNgrams, err = ParseNgramRange(val); err != nil {
+			return nil, err
+		}
+	}
+	if val := os.Getenv(CharNgramsEnvVar); val != "" {
+		if res.CharNgrams, err = ParseNgramRange(val); err != nil {
+			return nil, err
+		}
+	}
+	if res.WordNgrams.Max == 0 && res.CharNgrams.Max == 0 {
+		return nil, errors.New("no word or character n-grams")
+	}
+	if val := os.Getenv(LearningRateEnvVar); val != "" {
+		rate, err := strconv.ParseFloat(val, 64)
+		if err != nil || rate <= 0 {
+			return nil, errors.New("invalid learning rate: " + val)
+		}
+		res.LearningRate = rate
+	}
+	if val := os.Getenv(SeedEnvVar); val != "" {
+		seed, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid seed: " + val)
+		}
+		res.Seed = seed
+	}
+	return res, nil
+}
+
+func envPositiveInt(name string, defaultVal int) (int, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return defaultVal, nil
+	}
+	res, err := strconv.Atoi(val)
+	if err != nil || res < 1 {
+		return 0, errors.New("invalid " + name + ": " + val)
+	}
+	return res, nil
+}
+
+// Train trains a Classifier using TrainerParams
+// from EnvTrainerParams.
+func Train(data map[string][]tokens.Freqs) *Classifier {
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	res, err := TrainParams(data, params)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// TrainParams trains a Classifier on token
+// frequencies, which provide words and character
+// n-grams but not longer word n-grams.
+// It fails if p allows neither, since there would
+// be no features to train on.
+func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) (*Classifier, error) {
+	if p.WordNgrams.Min != 1 && p.CharNgrams.Max == 0 {
+		return nil, errors.New("token frequencies need unigrams or character n-grams")
+	}
+	f := paramsFeaturizer(p)
+	var langs []string
+	for lang := range data {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	if len(langs) == 0 {
+		return nil, errors.New("no samples to train on")
+	}
+	samples := newSampleSet()
+	for i, lang := range langs {
+		for _, sample := range data[lang] {
+			samples.add(f.freqsFeatures(sample), i)
+		}
+	}
+	return train(langs, samples, p)
+}
+
+// TrainText trains a Classifier on the text of
+// every document from a tokens.TextReader, split
+// into chunks of p.ChunkLines lines.
+// Only the hashed features of each chunk are kept
+// in memory, taking 8 bytes per feature.
+func TrainText(r tokens.TextReader, p *TrainerParams) (*Classifier, error) {
+	f := paramsFeaturizer(p)
+	var langs []string
+	langIndices := map[string]int{}
+	samples := newSampleSet()
+	for {
+		lang, text, err := r.ReadText()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		idx, ok := langIndices[lang]
+		if !ok {
+			idx = len(langs)
+			langIndices[lang] = idx
+			langs = append(langs, lang)
+		}
+		for _, chunk := range splitLines(text, p.ChunkLines) {
+			samples.add(f.textFeatures(chunk), idx)
+		}
+	}
+	if len(langs) == 0 {
+		return nil, errors.New("no samples to train on")
+	}
+	return train(langs, samples, p)
+}
+
+// splitLines splits text into chunks of up to n
+// lines, skipping chunks with no words.
+// If n is 0, the text is not split.
+func splitLines(text string, n int) []string {
+	if n == 0 {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return []string{text}
+	}
+	var res []string
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i += n {
+		end := i + n
+		if end > len(lines) {
+			end = len(lines)
+		}
+		chunk := strings.Join(lines[i:end], "\n")
+		if strings.TrimSpace(chunk) != "" {
+			res = append(res, chunk)
+		}
+	}
+	return res
+}
+
+func paramsFeaturizer(p *TrainerParams) *featurizer {
+	return &featurizer{
+		buckets:    p.Buckets,
+		wordNgrams: p.WordNgrams,
+		charNgrams: p.CharNgrams,
+	}
+}
+
+// A trainingSample refers to the embedding row of
+// each of its features, rather than the bucket, and
+// stores the feature weights as float32s, since
+// every sample stays in memory during training.
+type trainingSample struct {
+	rows    []int32
+	weights []float32
+	lang    int
+}
+
+// A sampleSet assigns embedding rows to buckets as
+// samples are added, so that only the used buckets
+// need rows.
+type sampleSet struct {
+	samples    []trainingSample
+	buckets    []int
+	rowIndices map[int]int32
+}
+
+func newSampleSet() *sampleSet {
+	return &sampleSet{rowIndices: map[int]int32{}}
+}
+
+func (s *sampleSet) add(f *features, lang int) {
+	sample := trainingSample{
+		rows:    make([]int32, len(f.buckets)),
+		weights: make([]float32, len(f.weights)),
+		lang:    lang,
+	}
+	for i, bucket := range f.buckets {
+		row, ok := s.rowIndices[bucket]
+		if !ok {
+			row = int32(len(s.buckets))
+			s.rowIndices[bucket] = row
+			s.buckets = append(s.buckets, bucket)
+		}
+		sample.rows[i] = row
+		sample.weights[i] = float32(f.weights[i])
+	}
+	s.samples = append(s.samples, sample)
+}
+
+// sortRows renumbers the rows in place so that
+// they are in increasing order of bucket, and
+// returns the bucket of each row.
+func (s *sampleSet) sortRows() []int {
+	buckets := append([]int{}, s.buckets...)
+	sort.Ints(buckets)
+	newRows := make([]int32, len(buckets))
+	for i, bucket := range buckets {
+		newRows[s.rowIndices[bucket]] = int32(i)
+	}
+	for _, sample := range s.samples {
+		for i, row := range sample.rows {
+			sample.rows[i] = newRows[row]
+		}
+	}
+	s.buckets = buckets
+	s.rowIndices = nil
+	return buckets
+}
+
+// train runs SGD on the softmax cross-entropy loss,
+// one sample at a time.
+// It fails if no sample has any features.
+func train(langs []string, set *sampleSet, p *TrainerParams) (*Classifier, error) {
+	if len(set.buckets) == 0 {
+		return nil, errors.New("no features in any sample")
+	}
+	r := rand.New(rand.NewSource(p.Seed))
+	if p.Verbose {
+		log.Printf("using seed %d", p.Seed)
+	}
+
+	samples := set.samples
+	res := &Classifier{
+		Langs:         langs,
+		Dim:           p.Dim,
+		Buckets:       p.Buckets,
+		WordNgrams:    p.WordNgrams,
+		CharNgrams:    p.CharNgrams,
+		Rows:          set.sortRows(),
+		OutputWeights: make([][]float64, len(langs)),
+		OutputBiases:  make([]float64, len(langs)),
+	}
+
+	embeddings := make([]float64, len(res.Rows)*p.Dim)
+	for i := range embeddings {
+		embeddings[i] = (r.Float64()*2 - 1) / float64(p.Dim)
+	}
+	for i := range res.OutputWeights {
+		res.OutputWeights[i] = make([]float64, p.Dim)
+	}
+
+	hidden := make([]float64, p.Dim)
+	hiddenGrad := make([]float64, p.Dim)
+	totalSteps := float64(p.Epochs * len(samples))
+	var step int
+	for epoch := 0; epoch < p.Epochs; epoch++ {
+		var totalLoss float64
+		for _, idx := range r.Perm(len(samples)) {
+			s := samples[idx]
+			rate := p.LearningRate * (1 - float64(step)/totalSteps)
+			step++
+
+			for j := range hidden {
+				hidden[j] = 0
+				hiddenGrad[j] = 0
+			}
+			for k, row := range s.rows {
+				weight := float64(s.weights[k])
+				start := int(row) * p.Dim
+				for j, x := range embeddings[start : start+p.Dim] {
+					hidden[j] += weight * x
+				}
+			}
+
+			probs := outputProbs(hidden, res.OutputWeights, res.OutputBiases)
+			totalLoss -= math.Log(math.Max(probs[s.lang], 1e-300))
+			probs[s.lang]--
+			for i, g := range probs {
+				weights := res.OutputWeights[i]
+				for j, x := range hidden {
+					hiddenGrad[j] += g * weights[j]
+					weights[j] -= rate * g * x
+				}
+				res.OutputBiases[i] -= rate * g
+			}
+
+			for k, row := range s.rows {
+				scale := rate * float64(s.weights[k])
+				start := int(row) * p.Dim
+				vec := embeddings[start : start+p.Dim]
+				for j, g := range hiddenGrad {
+					vec[j] -= scale * g
+				}
+			}
+		}
+		if p.Verbose {
+			log.Printf("epoch %d: loss=%f", epoch, totalLoss/float64(len(samples)))
+		}
+	}
+
+	res.Embeddings = make([]byte, 4*len(embeddings))
+	for i, x := range embeddings {
+		bits := math.Float32bits(float32(x))
+		binary.LittleEndian.PutUint32(res.Embeddings[i*4:], bits)
+	}
+	return res, nil
+}
@@ -1,11 +1,16 @@
 // Package gaussbayes implements naive Bayesian
 // classification using the assumption that token
-// frequencies follow Gaussian distributions.
+// frequencies follow Gaussian distributions, or
+// one of several related distributions which are
+// better suited to frequencies that are often 0.
 package gaussbayes
 
 import (
 	"encoding/json"
+	"errors"
 	"math"
+	"sort"
+	"sync"
 
 	"github.com/unixpickle/whichlang/tokens"
 )
@@ -25,7 +30,49 @@ func (g Gaussian) EvalLog(x float64) float64 {
 }
 
 type Classifier struct {
-	LangGaussians map[string]map[string]Gaussian
+	Langs  []string
+	Tokens []string
+
+	// Distribution is the family of distributions
+	// used for every token.
+	// Classifiers from older versions of this package
+	// use GaussianDistribution.
+	Distribution Distribution `json:",omitempty"`
+
+	// Means and Variances store the parameters of
+	// the distribution for each token in each
+	// language, indexed like Langs and Tokens.
+	//
+	// For ZeroInflatedDistribution, they describe the
+	// non-zero frequencies.
+	// For LogNormalDistribution, they describe the
+	// logs of the offset frequencies.
+	// For PoissonDistribution, Means stores the rate
+	// of each token and Variances is nil.
+	Means     [][]float64
+	Variances [][]float64 `json:",omitempty"`
+
+	// ZeroProbs stores the probability that each
+	// token is absent for ZeroInflatedDistribution.
+	ZeroProbs [][]float64 `json:",omitempty"`
+
+	// LogOffset is added to frequencies before taking
+	// their logs for LogNormalDistribution.
+	LogOffset float64 `json:",omitempty"`
+
+	// DocumentLength converts frequencies to counts
+	// for PoissonDistribution.
+	DocumentLength float64 `json:",omitempty"`
+
+	// LangGaussians stores the Gaussians for each
+	// language and token in classifiers encoded by
+	// older versions of this package.
+	// DecodeClassifier converts it to the fields
+	// above.
+	LangGaussians map[string]map[string]Gaussian `json:",omitempty"`
+
+	scorerOnce sync.Once
+	scorer     *scorer
 }
 
 func DecodeClassifier(d []byte) (*Classifier, error) {
@@ -33,23 +80,93 @@ func DecodeClassifier(d []byte) (*Classifier, error) {
 	if err := json.Unmarshal(d, &c); err != nil {
 		return nil, err
 	}
+	if c.LangGaussians != nil {
+		return newClassifier(c.LangGaussians), nil
+	}
+	switch c.Distribution {
+	case GaussianDistribution:
+	case ZeroInflatedDistribution:
+		if err := checkShape(c.ZeroProbs, len(c.Langs), len(c.Tokens)); err != nil {
+			return nil, err
+		}
+	case LogNormalDistribution:
+		if c.LogOffset <= 0 {
+			return nil, errors.New("invalid log offset")
+		}
+	case PoissonDistribution:
+		if c.DocumentLength <= 0 {
+			return nil, errors.New("invalid document length")
+		}
+	default:
+		return nil, errors.New("unknown distribution: " + c.Distribution.String())
+	}
+	if err := checkShape(c.Means, len(c.Langs), len(c.Tokens)); err != nil {
+		return nil, err
+	}
+	if c.Distribution != PoissonDistribution {
+		if err := checkShape(c.Variances, len(c.Langs), len(c.Tokens)); err != nil {
+			return nil, err
+		}
+	}
 	return &c, nil
 }
 
-func (c *Classifier) Classify(f tokens.Freqs) string {
-	var bestLanguage string
-	var bestLogProbability float64
-	for lang, dists := range c.LangGaussians {
-		var probLog float64
-		for token, gaussian := range dists {
-			probLog += gaussian.EvalLog(f[token])
+func checkShape(m [][]float64, langCount, tokenCount int) error {
+	if len(m) != langCount {
+		return errors.New("mismatched language count")
+	}
+	for _, row := range m {
+		if len(row) != tokenCount {
+			return errors.New("mismatched token count")
+		}
+	}
+	return nil
+}
+
+// newClassifier creates a Classifier from maps of
+// Gaussians for each language and token.
+func newClassifier(langGaussians map[string]map[string]Gaussian) *Classifier {
+	res := &Classifier{}
+	tokenSet := map[string]bool{}
+	for lang, dists := range langGaussians {
+		res.Langs = append(res.Langs, lang)
+		for token := range dists {
+			tokenSet[token] = true
 		}
-		if bestLanguage == "" || probLog > bestLogProbability {
-			bestLanguage = lang
-			bestLogProbability = probLog
+	}
+	for token := range tokenSet {
+		res.Tokens = append(res.Tokens, token)
+	}
+	sort.Strings(res.Langs)
+	sort.Strings(res.Tokens)
+
+	for _, lang := range res.Langs {
+		means := make([]float64, len(res.Tokens))
+		variances := make([]float64, len(res.Tokens))
+		for i, token := range res.Tokens {
+			g := langGaussians[lang][token]
+			means[i] = g.Mean
+			variances[i] = g.Variance
 		}
+		res.Means = append(res.Means, means)
+		res.Variances = append(res.Variances, variances)
 	}
-	return bestLanguage
+	return res
+}
+
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	c.scorerOnce.Do(func() {
+		c.scorer = newScorer(c)
+	})
+	scores := c.scorer.Scores(f)
+
+	var bestIdx int
+	for i, score := range scores {
+		if score > scores[bestIdx] {
+			bestIdx = i
+		}
+	}
+	return c.Langs[bestIdx]
 }
 
 func (c *Classifier) Encode() []byte {
@@ -58,9 +175,133 @@ func (c *Classifier) Encode() []byte {
 }
 
 func (c *Classifier) Languages() []string {
-	var languages []string
-	for lang := range c.LangGaussians {
-		languages = append(languages, lang)
+	return c.Langs
+}
+
+// A scorer computes the log-likelihood of each
+// language for a document in time proportional to
+// the number of tokens in the document.
+//
+// It starts from the log-likelihood of a document
+// in which every token has frequency 0, and then
+// corrects the terms for tokens which appear.
+// For a Gaussian, the correction is
+//
+//	log N(x; m, v) - log N(0; m, v) = x*(2m - x)/(2v)
+//
+// Terms which are the same for every language,
+// such as the log-normal Jacobian and log(k!) for
+// Poisson counts, are omitted.
+type scorer struct {
+	distribution Distribution
+	tokenIndices map[string]int
+
+	// zeroLogs stores the log-likelihood of the
+	// all-zero document for each language.
+	zeroLogs []float64
+
+	means [][]float64
+
+	// halfPrecisions[i][j] is 1/(2v) for token j in
+	// language i.
+	halfPrecisions [][]float64
+
+	// presentLogs[i][j] is the part of the correction
+	// for a zero-inflated token which does not depend
+	// on its frequency.
+	presentLogs [][]float64
+
+	// logMeans[i][j] is the log of the Poisson rate.
+	logMeans [][]float64
+
+	logOffset      float64
+	documentLength float64
+}
+
+func newScorer(c *Classifier) *scorer {
+	res := &scorer{
+		distribution:   c.Distribution,
+		tokenIndices:   map[string]int{},
+		zeroLogs:       make([]float64, len(c.Langs)),
+		means:          c.Means,
+		halfPrecisions: make([][]float64, len(c.Langs)),
+		logOffset:      c.LogOffset,
+		documentLength: c.DocumentLength,
+	}
+	for i, token := range c.Tokens {
+		res.tokenIndices[token] = i
 	}
-	return languages
+	if c.Distribution == PoissonDistribution {
+		res.logMeans = make([][]float64, len(c.Langs))
+		for i, means := range c.Means {
+			res.logMeans[i] = make([]float64, len(means))
+			for j, mean := range means {
+				res.zeroLogs[i] -= mean
+				res.logMeans[i][j] = math.Log(mean)
+			}
+		}
+		return res
+	}
+	if c.Distribution == ZeroInflatedDistribution {
+		res.presentLogs = make([][]float64, len(c.Langs))
+	}
+	zeroValue := 0.0
+	if c.Distribution == LogNormalDistribution {
+		zeroValue = math.Log(c.LogOffset)
+	}
+	for i := range c.Langs {
+		res.halfPrecisions[i] = make([]float64, len(c.Tokens))
+		if res.presentLogs != nil {
+			res.presentLogs[i] = make([]float64, len(c.Tokens))
+		}
+		for j, mean := range c.Means[i] {
+			g := Gaussian{Mean: mean, Variance: c.Variances[i][j]}
+			res.halfPrecisions[i][j] = 1 / (2 * g.Variance)
+			if res.presentLogs != nil {
+				zeroProb := c.ZeroProbs[i][j]
+				res.zeroLogs[i] += math.Log(zeroProb)
+				res.presentLogs[i][j] = math.Log(1-zeroProb) - math.Log(zeroProb) -
+					0.5*math.Log(2*math.Pi*g.Variance)
+			} else {
+				res.zeroLogs[i] += g.EvalLog(zeroValue)
+			}
+		}
+	}
+	return res
+}
+
+// Scores returns the log-likelihood of the document
+// for each language.
+func (s *scorer) Scores(f tokens.Freqs) []float64 {
+	res := make([]float64, len(s.zeroLogs))
+	copy(res, s.zeroLogs)
+	for token, x := range f {
+		j, ok := s.tokenIndices[token]
+		if !ok || x == 0 {
+			continue
+		}
+		switch s.distribution {
+		case GaussianDistribution:
+			for i, halfPrecisions := range s.halfPrecisions {
+				res[i] += x * (2*s.means[i][j] - x) * halfPrecisions[j]
+			}
+		case ZeroInflatedDistribution:
+			for i, halfPrecisions := range s.halfPrecisions {
+				diff := x - s.means[i][j]
+				res[i] += s.presentLogs[i][j] - diff*diff*halfPrecisions[j]
+			}
+		case LogNormalDistribution:
+			y0 := math.Log(s.logOffset)
+			y := math.Log(x + s.logOffset)
+			for i, halfPrecisions := range s.halfPrecisions {
+				res[i] += (y - y0) * (2*s.means[i][j] - y - y0) * halfPrecisions[j]
+			}
+		case PoissonDistribution:
+			k := x * s.documentLength
+			for i, logMeans := range s.logMeans {
+				res[i] += k * logMeans[j]
+			}
+		}
+	}
+	return res
 }
@@ -0,0 +1,100 @@
+package gaussbayes
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func TestScorerMatchesEvalLog(t *testing.T) {
+	data := map[string][]tokens.Freqs{}
+	for _, lang := range []string{"A", "B", "C"} {
+		for i := 0; i < 20; i++ {
+			data[lang] = append(data[lang], randomFreqs())
+		}
+	}
+	for _, d := range AllDistributions {
+		c := TrainDistribution(data, d)
+		s := newScorer(c)
+		for i := 0; i < 10; i++ {
+			f := randomFreqs()
+			f["unseen"] = 0.1
+			expected := bruteForceLogs(c, f)
+			actual := s.Scores(f)
+
+			// The scorer omits terms which are the same
+			// for every language.
+			for j := range c.Langs {
+				expectedDiff := expected[j] - expected[0]
+				actualDiff := actual[j] - actual[0]
+				if math.Abs(actualDiff-expectedDiff) > 1e-6*math.Max(1, math.Abs(expectedDiff)) {
+					t.Errorf("%s: language %d: expected %f but got %f", d, j,
+						expectedDiff, actualDiff)
+				}
+			}
+		}
+	}
+}
+
+func TestGaussianVariances(t *testing.T) {
+	// Variances only sum squared deviations over the
+	// samples which contain a token, as they always
+	// have.
+	data := map[string][]tokens.Freqs{
+		"A": {{"x": 0.5, "y": 0.5}, {"x": 1}, {"y": 1}},
+		"B": {{"x": 0.2, "y": 0.8}},
+	}
+	c := TrainDistribution(data, GaussianDistribution)
+	mean := 1.5 / 3
+	expected := (0 + math.Pow(1-mean, 2)) / 3
+	if actual := c.Variances[0][0]; math.Abs(actual-expected) > 1e-12 {
+		t.Errorf("expected variance %f but got %f", expected, actual)
+	}
+}
+
+// bruteForceLogs evaluates the log-likelihood of
+// every token, present or not, for each language.
+func bruteForceLogs(c *Classifier, f tokens.Freqs) []float64 {
+	res := make([]float64, len(c.Langs))
+	for i := range c.Langs {
+		for j, token := range c.Tokens {
+			x := f[token]
+			mean := c.Means[i][j]
+			switch c.Distribution {
+			case GaussianDistribution:
+				res[i] += Gaussian{mean, c.Variances[i][j]}.EvalLog(x)
+			case ZeroInflatedDistribution:
+				zeroProb := c.ZeroProbs[i][j]
+				if x == 0 {
+					res[i] += math.Log(zeroProb)
+				} else {
+					res[i] += math.Log(1-zeroProb) + Gaussian{mean, c.Variances[i][j]}.EvalLog(x)
+				}
+			case LogNormalDistribution:
+				y := math.Log(x + c.LogOffset)
+				res[i] += Gaussian{mean, c.Variances[i][j]}.EvalLog(y) - y
+			case PoissonDistribution:
+				k := x * c.DocumentLength
+				logFact, _ := math.Lgamma(k + 1)
+				res[i] += k*math.Log(mean) - mean - logFact
+			}
+		}
+	}
+	return res
+}
+
+func randomFreqs() tokens.Freqs {
+	counts := tokens.Counts{}
+	for i := 0; i < 10; i++ {
+		if rand.Intn(3) != 0 {
+			counts["tok"+strconv.Itoa(i)] = rand.Intn(10) + 1
+		}
+	}
+	if len(counts) == 0 {
+		counts["tok0"] = 1
+	}
+	return counts.Freqs()
+}
@@ -0,0 +1,68 @@
+package gaussbayes
+
+import (
+	"errors"
+	"strconv"
+)
+
+// A Distribution is a family of probability
+// distributions used to model each token's
+// frequencies in a language.
+type Distribution int
+
+const (
+	// GaussianDistribution models frequencies with a
+	// Gaussian.
+	// This is the distribution used by classifiers
+	// from older versions of this package.
+	GaussianDistribution Distribution = iota
+
+	// ZeroInflatedDistribution models the probability
+	// that a token is absent, plus a Gaussian over the
+	// token's non-zero frequencies.
+	ZeroInflatedDistribution
+
+	// LogNormalDistribution models the logarithm of
+	// each frequency (plus a small offset, so that
+	// zero frequencies are defined) with a Gaussian.
+	LogNormalDistribution
+
+	// PoissonDistribution models the number of times
+	// a token appears in a document with a Poisson
+	// distribution.
+	// Counts are recovered from frequencies by
+	// assuming a fixed document length.
+	PoissonDistribution
+)
+
+// AllDistributions lists every Distribution, in the
+// order that they are preferred when they perform
+// equally well.
+var AllDistributions = []Distribution{GaussianDistribution, ZeroInflatedDistribution,
+	LogNormalDistribution, PoissonDistribution}
+
+// ParseDistribution parses the name of a
+// Distribution, such as "lognormal".
+func ParseDistribution(name string) (Distribution, error) {
+	for _, d := range AllDistributions {
+		if d.String() == name {
+			return d, nil
+		}
+	}
+	return 0, errors.New("unknown distribution: " + name)
+}
+
+func (d Distribution) String() string {
+	switch d {
+	case GaussianDistribution:
+		return "gaussian"
+	case ZeroInflatedDistribution:
+		return "zeroinflated"
+	case LogNormalDistribution:
+		return "lognormal"
+	case PoissonDistribution:
+		return "poisson"
+	default:
+		return "Distribution(" + strconv.Itoa(int(d)) + ")"
+	}
+}
@@ -2,69 +2,283 @@ package gaussbayes
 
 import (
 	"math"
+	"math/rand"
+	"os"
+	"sort"
 
 	"github.com/unixpickle/whichlang/tokens"
 )
 
+// ValidationFraction is the fraction of samples
+// withheld to choose a Distribution.
+const ValidationFraction = 0.3
+
+// DefaultDocumentLength is the number of tokens
+// assumed to be in each document, used to turn
+// frequencies into counts for PoissonDistribution.
+const DefaultDocumentLength = 100
+
+// poissonPseudoCount is added to the total count of
+// each token in each language, as if every language
+// had one extra sample, so that no rate is zero.
+const poissonPseudoCount = 0.5
+
+// varianceEpsilon is the smallest variance, relative
+// to the mean square, that is not rounding error.
+const varianceEpsilon = 1e-10
+
+// DistributionEnvVar is an environment variable
+// which may be set to "gaussian", "zeroinflated",
+// "lognormal", or "poisson" to choose the
+// Distribution.
+// By default, every Distribution is tried and the
+// most accurate on withheld samples is chosen.
+const DistributionEnvVar = "GAUSSBAYES_DISTRIBUTION"
+
 // Train returns a *Classifier by computing statistical
 // properties of the sample data.
 func Train(freqs map[string][]tokens.Freqs) *Classifier {
-	res := &Classifier{LangGaussians: map[string]map[string]Gaussian{}}
-	tokens := allTokens(freqs)
-	for lang, samples := range freqs {
-		gaussians := computeGaussians(samples)
-		addMissing(gaussians, tokens)
-		res.LangGaussians[lang] = gaussians
+	if val := os.Getenv(DistributionEnvVar); val != "" {
+		d, err := ParseDistribution(val)
+		if err != nil {
+			panic(err)
+		}
+		return TrainDistribution(freqs, d)
 	}
-	regularizeVariances(res)
-	return res
+	return TrainDistribution(freqs, bestDistribution(freqs))
 }
 
-func computeGaussians(samples []tokens.Freqs) map[string]Gaussian {
-	res := map[string]Gaussian{}
+// TrainDistribution returns a *Classifier which
+// models token frequencies with the given
+// Distribution.
+func TrainDistribution(freqs map[string][]tokens.Freqs, d Distribution) *Classifier {
+	res := &Classifier{
+		Distribution: d,
+		Tokens:       allTokens(freqs),
+	}
+	for lang := range freqs {
+		res.Langs = append(res.Langs, lang)
+	}
+	sort.Strings(res.Langs)
+
+	if d == LogNormalDistribution {
+		res.LogOffset = logOffset(freqs)
+	} else if d == PoissonDistribution {
+		res.DocumentLength = DefaultDocumentLength
+	}
+
+	tokenIndices := map[string]int{}
+	for i, token := range res.Tokens {
+		tokenIndices[token] = i
+	}
+	var globalStats []tokenStats
+	if d == ZeroInflatedDistribution {
+		globalStats = make([]tokenStats, len(res.Tokens))
+	}
+
+	for _, lang := range res.Langs {
+		samples := freqs[lang]
+		stats := computeStats(samples, tokenIndices, res.LogOffset)
+		n := float64(len(samples))
+		means := make([]float64, len(res.Tokens))
+		variances := make([]float64, len(res.Tokens))
+		var zeroProbs []float64
+		if d == ZeroInflatedDistribution {
+			zeroProbs = make([]float64, len(res.Tokens))
+		}
+
+		for i, s := range stats {
+			switch d {
+			case GaussianDistribution:
+				means[i] = s.Sum / n
+			case ZeroInflatedDistribution:
+				// Laplace smoothing keeps the probability of
+				// absence strictly between 0 and 1.
+				zeroProbs[i] = (n - s.NonZero + 1) / (n + 2)
+				means[i], variances[i] = meanVariance(s.Sum, s.SumSq, s.NonZero)
+				globalStats[i].add(s)
+			case LogNormalDistribution:
+				// Zero frequencies contribute log(offset).
+				zeroLog := math.Log(res.LogOffset)
+				zeros := n - s.NonZero
+				means[i], variances[i] = meanVariance(s.LogSum+zeros*zeroLog,
+					s.LogSumSq+zeros*zeroLog*zeroLog, n)
+			case PoissonDistribution:
+				counts := s.Sum * res.DocumentLength
+				means[i] = (counts + poissonPseudoCount) / (n + 1)
+			default:
+				panic("unknown distribution: " + d.String())
+			}
+		}
+
+		if d == GaussianDistribution {
+			variances = presentVariances(samples, tokenIndices, means)
+		}
 
-	computeMeans(samples, res)
-	computeVariances(samples, res)
+		res.Means = append(res.Means, means)
+		if d != PoissonDistribution {
+			res.Variances = append(res.Variances, variances)
+		}
+		if zeroProbs != nil {
+			res.ZeroProbs = append(res.ZeroProbs, zeroProbs)
+		}
+	}
+
+	if d == ZeroInflatedDistribution {
+		// Tokens which never appear in a language use
+		// the token's non-zero frequencies from every
+		// language.
+		for i := range res.Langs {
+			for j, g := range globalStats {
+				if math.IsNaN(res.Means[i][j]) {
+					res.Means[i][j], res.Variances[i][j] = meanVariance(g.Sum, g.SumSq,
+						g.NonZero)
+				}
+			}
+		}
+	}
+	if res.Variances != nil {
+		regularizeVariances(res.Variances)
+	}
 
 	return res
 }
 
-func computeMeans(samples []tokens.Freqs, out map[string]Gaussian) {
+// bestDistribution chooses the Distribution which
+// classifies withheld samples most accurately.
+func bestDistribution(freqs map[string][]tokens.Freqs) Distribution {
+	training := map[string][]tokens.Freqs{}
+	validation := map[string][]tokens.Freqs{}
+	for lang, samples := range freqs {
+		numValid := int(float64(len(samples)) * ValidationFraction)
+		for i, idx := range rand.Perm(len(samples)) {
+			if i < numValid {
+				validation[lang] = append(validation[lang], samples[idx])
+			} else {
+				training[lang] = append(training[lang], samples[idx])
+			}
+		}
+	}
+
+	best := GaussianDistribution
+	bestCorrect := -1
+	for _, d := range AllDistributions {
+		c := TrainDistribution(training, d)
+		var correct int
+		for lang, samples := range validation {
+			for _, sample := range samples {
+				if c.Classify(sample) == lang {
+					correct++
+				}
+			}
+		}
+		if correct > bestCorrect {
+			best = d
+			bestCorrect = correct
+		}
+	}
+	return best
+}
+
+// tokenStats stores sums over the non-zero
+// frequencies of a token.
+type tokenStats struct {
+	NonZero float64
+
+	Sum   float64
+	SumSq float64
+
+	// LogSum and LogSumSq are computed over the logs
+	// of the offset frequencies.
+	LogSum   float64
+	LogSumSq float64
+}
+
+func (t *tokenStats) add(t1 tokenStats) {
+	t.NonZero += t1.NonZero
+	t.Sum += t1.Sum
+	t.SumSq += t1.SumSq
+	t.LogSum += t1.LogSum
+	t.LogSumSq += t1.LogSumSq
+}
+
+func computeStats(samples []tokens.Freqs, tokenIndices map[string]int,
+	offset float64) []tokenStats {
+	res := make([]tokenStats, len(tokenIndices))
 	for _, sample := range samples {
-		for keyword, freq := range sample {
-			outGaussian := out[keyword]
-			outGaussian.Mean += freq
-			out[keyword] = outGaussian
+		for token, freq := range sample {
+			if freq == 0 {
+				continue
+			}
+			s := &res[tokenIndices[token]]
+			s.NonZero++
+			s.Sum += freq
+			s.SumSq += freq * freq
+			if offset != 0 {
+				logFreq := math.Log(freq + offset)
+				s.LogSum += logFreq
+				s.LogSumSq += logFreq * logFreq
+			}
 		}
 	}
-	meanScaler := 1 / float64(len(samples))
-	for key, g := range out {
-		g.Mean *= meanScaler
-		out[key] = g
+	return res
+}
+
+// meanVariance computes the mean and variance of
+// values from their sum and sum of squares.
+// If there are no values, both results are NaN.
+//
+// Variances which are indistinguishable from
+// rounding error are reported as 0, so that
+// regularizeVariances replaces them.
+func meanVariance(sum, sumSq, count float64) (mean, variance float64) {
+	if count == 0 {
+		return math.NaN(), math.NaN()
 	}
+	mean = sum / count
+	variance = sumSq/count - mean*mean
+	if variance <= varianceEpsilon*sumSq/count {
+		variance = 0
+	}
+	return
 }
 
-func computeVariances(samples []tokens.Freqs, out map[string]Gaussian) {
+// presentVariances computes the variances for
+// GaussianDistribution the way this package always
+// has: squared deviations from the mean are summed
+// over the samples which contain each token, and
+// divided by the total number of samples.
+func presentVariances(samples []tokens.Freqs, tokenIndices map[string]int,
+	means []float64) []float64 {
+	res := make([]float64, len(means))
 	for _, sample := range samples {
-		for keyword, freq := range sample {
-			outGaussian := out[keyword]
-			outGaussian.Variance += math.Pow(freq-outGaussian.Mean, 2)
-			out[keyword] = outGaussian
+		for token, freq := range sample {
+			i := tokenIndices[token]
+			res[i] += (freq - means[i]) * (freq - means[i])
 		}
 	}
-	varianceScaler := 1 / float64(len(samples))
-	for key, g := range out {
-		g.Variance *= varianceScaler
-		out[key] = g
+	scaler := 1 / float64(len(samples))
+	for i := range res {
+		res[i] *= scaler
 	}
+	return res
 }
 
-func addMissing(m map[string]Gaussian, tokens []string) {
-	for _, token := range tokens {
-		if _, ok := m[token]; !ok {
-			m[token] = Gaussian{}
+// logOffset returns half of the smallest non-zero
+// frequency, which is small enough to separate
+// absent tokens from rare tokens.
+func logOffset(freqs map[string][]tokens.Freqs) float64 {
+	smallest := 1.0
+	for _, samples := range freqs {
+		for _, sample := range samples {
+			for _, freq := range sample {
+				if freq > 0 && freq < smallest {
+					smallest = freq
+				}
+			}
 		}
 	}
+	return smallest / 2
 }
 
 func allTokens(m map[string][]tokens.Freqs) []string {
@@ -80,24 +294,25 @@ func allTokens(m map[string][]tokens.Freqs) []string {
 	for w := range res {
 		resSlice = append(resSlice, w)
 	}
+	sort.Strings(resSlice)
 	return resSlice
 }
 
-// regularizeVariances ensures that no variances are zero.
-func regularizeVariances(c *Classifier) {
+// regularizeVariances ensures that no variances are
+// zero (or undefined).
+func regularizeVariances(variances [][]float64) {
 	var smallestVariance float64
-	for _, m := range c.LangGaussians {
-		for _, x := range m {
-			if smallestVariance == 0 || (x.Variance < smallestVariance && x.Variance > 0) {
-				smallestVariance = x.Variance
+	for _, vs := range variances {
+		for _, x := range vs {
+			if smallestVariance == 0 || (x < smallestVariance && x > 0) {
+				smallestVariance = x
 			}
 		}
 	}
-	for _, m := range c.LangGaussians {
-		for word, x := range m {
-			if x.Variance == 0 {
-				x.Variance = smallestVariance
-				m[word] = x
+	for _, vs := range variances {
+		for i, x := range vs {
+			if x == 0 || math.IsNaN(x) {
+				vs[i] = smallestVariance
 			}
 		}
 	}
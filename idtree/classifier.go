@@ -14,6 +14,12 @@ type Classifier struct {
 
 	FalseBranch *Classifier
 	TrueBranch  *Classifier
+
+	// Distribution maps languages to the number
+	// of training samples which reached this node.
+	// It is nil for classifiers trained before
+	// distributions were recorded.
+	Distribution map[string]int `json:",omitempty"`
 }
 
 func DecodeClassifier(d []byte) (*Classifier, error) {
@@ -0,0 +1,183 @@
+package idtree
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Condition is one test along the path
+// from the root of a tree to a leaf.
+type Condition struct {
+	Keyword   string
+	Threshold float64
+
+	// Greater is true if the condition
+	// requires the keyword's frequency to
+	// exceed the threshold, or false if it
+	// requires the frequency to be at most
+	// the threshold.
+	Greater bool
+}
+
+// String returns a human-readable version of
+// the condition, such as "freq(`func`) > 0.012".
+func (c Condition) String() string {
+	op := "<="
+	if c.Greater {
+		op = ">"
+	}
+	return fmt.Sprintf("freq(%s) %s %s", quoteKeyword(c.Keyword), op,
+		strconv.FormatFloat(c.Threshold, 'g', 6, 64))
+}
+
+// A Rule is a flattened path from the root
+// of a tree to one of its leaves.
+type Rule struct {
+	Conditions []Condition
+	Language   string
+
+	// Distribution is the leaf's distribution
+	// of training samples, or nil if it was
+	// not recorded.
+	Distribution map[string]int
+}
+
+// String returns the rule in the form
+// "if <condition> and ... then <language>".
+func (r Rule) String() string {
+	var buf bytes.Buffer
+	if len(r.Conditions) == 0 {
+		buf.WriteString("always")
+	} else {
+		buf.WriteString("if ")
+		for i, cond := range r.Conditions {
+			if i > 0 {
+				buf.WriteString(" and ")
+			}
+			buf.WriteString(cond.String())
+		}
+		buf.WriteString(" then")
+	}
+	buf.WriteString(" " + r.Language)
+	if r.Distribution != nil {
+		buf.WriteString(" " + distributionString(r.Distribution))
+	}
+	return buf.String()
+}
+
+// Rules flattens the tree into an ordered list
+// of rules, one per leaf.
+// Exactly one rule applies to any input, and
+// the rules are ordered so that false branches
+// come before true branches.
+func (c *Classifier) Rules() []Rule {
+	var res []Rule
+	c.appendRules(nil, &res)
+	return res
+}
+
+func (c *Classifier) appendRules(conds []Condition, res *[]Rule) {
+	if c.LeafClassification != nil {
+		rule := Rule{
+			Conditions:   make([]Condition, len(conds)),
+			Language:     *c.LeafClassification,
+			Distribution: c.Distribution,
+		}
+		copy(rule.Conditions, conds)
+		*res = append(*res, rule)
+		return
+	}
+	for _, greater := range []bool{false, true} {
+		cond := Condition{
+			Keyword:   c.Keyword,
+			Threshold: c.Threshold,
+			Greater:   greater,
+		}
+		branch := c.FalseBranch
+		if greater {
+			branch = c.TrueBranch
+		}
+		branch.appendRules(append(conds, cond), res)
+	}
+}
+
+// Graphviz renders the tree in the DOT language.
+// Every node shows its split or classification
+// along with its distribution of training samples,
+// when that distribution is available.
+func (c *Classifier) Graphviz() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph idtree {\n")
+	buf.WriteString("\tnode [shape=box];\n")
+	var nextID int
+	c.writeGraphviz(&buf, &nextID)
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+func (c *Classifier) writeGraphviz(buf *bytes.Buffer, nextID *int) int {
+	id := *nextID
+	*nextID++
+
+	var lines []string
+	if c.LeafClassification != nil {
+		lines = append(lines, *c.LeafClassification)
+	} else {
+		lines = append(lines, fmt.Sprintf("freq(%s) > %s", quoteKeyword(c.Keyword),
+			strconv.FormatFloat(c.Threshold, 'g', 6, 64)))
+	}
+	if c.Distribution != nil {
+		lines = append(lines, distributionString(c.Distribution))
+	}
+	for i, line := range lines {
+		lines[i] = dotEscape(line)
+	}
+	fmt.Fprintf(buf, "\tn%d [label=\"%s\"", id, strings.Join(lines, "\\n"))
+	if c.LeafClassification != nil {
+		buf.WriteString(", style=rounded")
+	}
+	buf.WriteString("];\n")
+
+	if c.LeafClassification == nil {
+		falseID := c.FalseBranch.writeGraphviz(buf, nextID)
+		trueID := c.TrueBranch.writeGraphviz(buf, nextID)
+		fmt.Fprintf(buf, "\tn%d -> n%d [label=\"false\"];\n", id, falseID)
+		fmt.Fprintf(buf, "\tn%d -> n%d [label=\"true\"];\n", id, trueID)
+	}
+
+	return id
+}
+
+// distributionString formats a distribution like
+// "{C: 3, Go: 12}", with languages sorted by name.
+func distributionString(dist map[string]int) string {
+	langs := make([]string, 0, len(dist))
+	for lang := range dist {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	parts := make([]string, len(langs))
+	for i, lang := range langs {
+		parts[i] = fmt.Sprintf("%s: %d", lang, dist[lang])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// quoteKeyword wraps a keyword in backticks,
+// falling back on a Go-quoted string if the
+// keyword contains whitespace or backticks
+// (e.g. line-initial tokens like "\nfunc").
+func quoteKeyword(k string) string {
+	if strings.ContainsAny(k, "`\n\t\r ") {
+		return strconv.Quote(k)
+	}
+	return "`" + k + "`"
+}
+
+func dotEscape(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	return strings.Replace(s, "\"", "\\\"", -1)
+}
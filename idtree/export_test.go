@@ -0,0 +1,36 @@
+package idtree
+
+import "testing"
+
+func TestRules(t *testing.T) {
+	goLang, cLang, pyLang := "Go", "C", "Python"
+	tree := &Classifier{
+		Keyword:   "func",
+		Threshold: 0.012,
+		FalseBranch: &Classifier{
+			Keyword:      "def",
+			Threshold:    0.5,
+			FalseBranch:  &Classifier{LeafClassification: &cLang},
+			TrueBranch:   &Classifier{LeafClassification: &pyLang},
+			Distribution: map[string]int{"C": 3, "Python": 2},
+		},
+		TrueBranch: &Classifier{
+			LeafClassification: &goLang,
+			Distribution:       map[string]int{"Go": 4},
+		},
+	}
+	expected := []string{
+		"if freq(`func`) <= 0.012 and freq(`def`) <= 0.5 then C",
+		"if freq(`func`) <= 0.012 and freq(`def`) > 0.5 then Python",
+		"if freq(`func`) > 0.012 then Go {Go: 4}",
+	}
+	rules := tree.Rules()
+	if len(rules) != len(expected) {
+		t.Fatal("expected", len(expected), "rules but got", len(rules))
+	}
+	for i, rule := range rules {
+		if rule.String() != expected[i] {
+			t.Errorf("rule %d: expected %q but got %q", i, expected[i], rule.String())
+		}
+	}
+}
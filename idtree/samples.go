@@ -25,10 +25,7 @@ func freqsToLinearSamples(toks []string, freqs map[string][]tokens.Freqs) []line
 }
 
 func languageMajority(samples []linearSample) string {
-	counts := map[string]int{}
-	for _, sample := range samples {
-		counts[sample.lang]++
-	}
+	counts := languageDistribution(samples)
 
 	var maxCount int
 	var maxLang string
@@ -42,6 +39,14 @@ func languageMajority(samples []linearSample) string {
 	return maxLang
 }
 
+func languageDistribution(samples []linearSample) map[string]int {
+	counts := map[string]int{}
+	for _, sample := range samples {
+		counts[sample.lang]++
+	}
+	return counts
+}
+
 // A sampleSorter implements sort.Interface
 // and facilitates sorting linear samples
 // by the frequency of a given token.
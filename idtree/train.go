@@ -47,11 +47,13 @@ func generateClassifier(toks []string, s []linearSample) *Classifier {
 		lang := languageMajority(s)
 		return &Classifier{
 			LeafClassification: &lang,
+			Distribution:       languageDistribution(s),
 		}
 	}
 	res := &Classifier{
-		Keyword:   toks[tokIdx],
-		Threshold: thresh,
+		Keyword:      toks[tokIdx],
+		Threshold:    thresh,
+		Distribution: languageDistribution(s),
 	}
 	f, t := splitData(s, tokIdx, thresh)
 	res.FalseBranch = generateClassifier(toks, f)
@@ -3,14 +3,40 @@ package knn
 import (
 	"encoding/json"
 	"math"
+	"sync"
 
-	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/whichlang/sparse"
 	"github.com/unixpickle/whichlang/tokens"
 )
 
+// distanceWeightEpsilon is added to distances
+// before inverting them for distance-weighted
+// voting, so that exact matches do not produce
+// infinite weights.
+const distanceWeightEpsilon = 1e-5
+
 type Sample struct {
 	Language string
-	Vector   linalg.Vector
+	Vector   sparse.Vector
+
+	// Source identifies the file from which the
+	// sample was created, if it is known.
+	Source string `json:",omitempty"`
+}
+
+// A Neighbor describes a sample which was one of
+// the nearest neighbors to a query.
+type Neighbor struct {
+	Language string
+	Source   string
+
+	// Distance is the distance to the sample
+	// under the classifier's Metric.
+	Distance float64
+
+	// Correlation is the cosine similarity of
+	// the sample and the query.
+	Correlation float64
 }
 
 type Classifier struct {
@@ -18,6 +44,24 @@ type Classifier struct {
 	Samples []Sample
 
 	NeighborCount int
+
+	// Metric is used to compare queries to samples.
+	// The samples' vectors have been prepared for
+	// this metric with Metric.Prepare.
+	Metric Metric
+
+	// DistanceWeighted indicates that neighbors
+	// vote with a weight inversely proportional to
+	// their distance, rather than with equal weights.
+	DistanceWeighted bool
+
+	// Index is used to find neighbors quickly.
+	// If it is nil, the classifier compares each
+	// query to every sample.
+	Index *VPTree `json:",omitempty"`
+
+	tokenIndicesOnce sync.Once
+	tokenIndices     map[string]int
 }
 
 func DecodeClassifier(d []byte) (*Classifier, error) {
@@ -25,24 +69,48 @@ func DecodeClassifier(d []byte) (*Classifier, error) {
 	if err := json.Unmarshal(d, &res); err != nil {
 		return nil, err
 	}
+
+	// Older classifiers scaled samples by the
+	// inverse of their squared magnitude.
+	// Normalizing is a no-op for newer ones.
+	if res.Metric == CosineMetric {
+		for i, sample := range res.Samples {
+			res.Samples[i].Vector = res.Metric.Prepare(sample.Vector)
+		}
+	}
+
 	return &res, nil
 }
 
 func (c *Classifier) Classify(f tokens.Freqs) string {
-	vec := make(linalg.Vector, len(c.Tokens))
-	for i, keyw := range c.Tokens {
-		vec[i] = f[keyw]
-	}
-
-	vecMag := vec.Dot(vec)
-	if vecMag == 0 {
+	vec := c.queryVector(f)
+	if c.Metric == CosineMetric && len(vec.Indices) == 0 {
 		return c.Samples[0].Language
 	}
-	vec.Scale(1 / math.Sqrt(vecMag))
-
 	return c.classifyVector(vec)
 }
 
+// Neighbors returns the samples which Classify
+// would use to classify f, sorted from nearest
+// to farthest.
+func (c *Classifier) Neighbors(f tokens.Freqs) []Neighbor {
+	vec := c.queryVector(f)
+	matches := c.matches(vec)
+	res := make([]Neighbor, len(matches))
+	for i, m := range matches {
+		sample := c.Samples[m.Index]
+		res[i] = Neighbor{
+			Language: sample.Language,
+			Source:   sample.Source,
+			Distance: m.Distance,
+		}
+		if norm := sample.Vector.Norm() * vec.Norm(); norm != 0 {
+			res[i].Correlation = sample.Vector.Dot(vec) / norm
+		}
+	}
+	return res
+}
+
 func (c *Classifier) Encode() []byte {
 	data, _ := json.Marshal(c)
 	return data
@@ -60,37 +128,72 @@ func (c *Classifier) Languages() []string {
 	return res
 }
 
-func (c *Classifier) classifyVector(vec linalg.Vector) string {
-	matches := make([]match, 0, c.NeighborCount)
-	for _, sample := range c.Samples {
-		correlation := sample.Vector.Dot(vec)
-		insertIdx := matchInsertionIndex(matches, correlation)
-		if insertIdx >= c.NeighborCount {
-			continue
-		}
-		if len(matches) < c.NeighborCount {
-			matches = append(matches, match{})
-		}
-		copy(matches[insertIdx+1:], matches[insertIdx:])
-		matches[insertIdx] = match{
-			Language:    sample.Language,
-			Correlation: correlation,
+// queryVector converts frequencies into a vector
+// which has been prepared for c.Metric.
+func (c *Classifier) queryVector(f tokens.Freqs) sparse.Vector {
+	c.tokenIndicesOnce.Do(func() {
+		c.tokenIndices = make(map[string]int, len(c.Tokens))
+		for i, tok := range c.Tokens {
+			c.tokenIndices[tok] = i
 		}
+	})
+	return c.Metric.Prepare(sparse.FreqsVector(c.tokenIndices, f))
+}
+
+func (c *Classifier) classifyVector(vec sparse.Vector) string {
+	return dominantClassification(c.matches(vec), c.DistanceWeighted)
+}
+
+func (c *Classifier) matches(vec sparse.Vector) []match {
+	if c.Index != nil {
+		return c.indexedMatches(vec)
 	}
+	return c.exactMatches(vec)
+}
 
-	return dominantClassification(matches)
+// exactMatches finds the nearest neighbors with
+// a linear scan over every sample.
+func (c *Classifier) exactMatches(vec sparse.Vector) []match {
+	return nearestMatches(c.Samples, c.Metric, vec, c.NeighborCount)
 }
 
-func dominantClassification(matches []match) string {
+// indexedMatches finds the nearest neighbors
+// by searching c.Index.
+func (c *Classifier) indexedMatches(vec sparse.Vector) []match {
+	neighbors := c.Index.nearest(c.Samples, c.Metric, vec, c.NeighborCount)
+	matches := make([]match, len(neighbors))
+	for i, n := range neighbors {
+		matches[i] = match{
+			Language: c.Samples[n.sample].Language,
+			Distance: n.distance,
+			Index:    n.sample,
+		}
+	}
+	return matches
+}
+
+// dominantClassification finds the language with
+// the most votes among a sorted list of matches.
+// Ties are broken in favor of the language with
+// the nearest match.
+func dominantClassification(matches []match, weighted bool) string {
 	scores := map[string]float64{}
+	var langs []string
 	for _, m := range matches {
-		scores[m.Language] += m.Correlation
+		if _, ok := scores[m.Language]; !ok {
+			langs = append(langs, m.Language)
+		}
+		if weighted {
+			scores[m.Language] += 1 / (m.Distance + distanceWeightEpsilon)
+		} else {
+			scores[m.Language]++
+		}
 	}
 
 	var bestLang string
 	bestScore := math.Inf(-1)
-	for lang, score := range scores {
-		if score > bestScore {
+	for _, lang := range langs {
+		if score := scores[lang]; score > bestScore {
 			bestScore = score
 			bestLang = lang
 		}
@@ -100,13 +203,40 @@ func dominantClassification(matches []match) string {
 }
 
 type match struct {
-	Language    string
-	Correlation float64
+	Language string
+	Distance float64
+
+	// Index is the index of the matching sample.
+	Index int
+}
+
+// nearestMatches finds the k samples nearest
+// to a vector using a linear scan.
+// The result is sorted by distance.
+func nearestMatches(samples []Sample, m Metric, vec sparse.Vector, k int) []match {
+	matches := make([]match, 0, k)
+	for i, sample := range samples {
+		distance := m.Distance(sample.Vector, vec)
+		insertIdx := matchInsertionIndex(matches, distance)
+		if insertIdx >= k {
+			continue
+		}
+		if len(matches) < k {
+			matches = append(matches, match{})
+		}
+		copy(matches[insertIdx+1:], matches[insertIdx:])
+		matches[insertIdx] = match{
+			Language: sample.Language,
+			Distance: distance,
+			Index:    i,
+		}
+	}
+	return matches
 }
 
-func matchInsertionIndex(m []match, corr float64) int {
+func matchInsertionIndex(m []match, distance float64) int {
 	for i, x := range m {
-		if x.Correlation < corr {
+		if x.Distance > distance {
 			return i
 		}
 	}
@@ -0,0 +1,198 @@
+package knn
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/unixpickle/whichlang/sparse"
+)
+
+// A VPTree is a vantage-point tree which indexes
+// the samples of a Classifier under its Metric.
+//
+// Each node stores one sample (the vantage point)
+// and a radius; samples closer to the vantage
+// point than the radius are stored in the inside
+// subtree and the rest in the outside subtree.
+type VPTree struct {
+	// Nodes stores the nodes of the tree, with
+	// the root (if any) at index 0.
+	Nodes []VPNode
+
+	// MaxChecks bounds the number of distances
+	// computed per query.
+	// Lower values trade accuracy for speed.
+	// If MaxChecks is 0, searches are exact.
+	MaxChecks int
+}
+
+// A VPNode is a node in a VPTree.
+type VPNode struct {
+	// Sample is the index of the vantage point
+	// in Classifier.Samples.
+	Sample int
+	Radius float64
+
+	// Inside and Outside are indices in
+	// VPTree.Nodes, or -1 for empty subtrees.
+	Inside  int
+	Outside int
+}
+
+// NewVPTree builds a VPTree for the given samples,
+// whose vectors have been prepared for m.
+func NewVPTree(samples []Sample, m Metric, maxChecks int) *VPTree {
+	res := &VPTree{MaxChecks: maxChecks}
+	indices := rand.Perm(len(samples))
+	res.build(samples, m, indices)
+	return res
+}
+
+// nearest returns (approximately) the k samples
+// nearest to a prepared vector.
+// The result is sorted from nearest to farthest.
+func (v *VPTree) nearest(samples []Sample, m Metric, vec sparse.Vector, k int) []neighbor {
+	if len(v.Nodes) == 0 || k == 0 {
+		return nil
+	}
+
+	var results neighborHeap
+	queue := &nodeQueue{{node: 0}}
+	var checks int
+	for queue.Len() > 0 {
+		entry := heap.Pop(queue).(nodeQueueEntry)
+		if len(results) == k && entry.bound > results[0].distance {
+			break
+		}
+		if v.MaxChecks > 0 && checks >= v.MaxChecks {
+			break
+		}
+
+		node := v.Nodes[entry.node]
+		dist := m.Distance(samples[node.Sample].Vector, vec)
+		checks++
+
+		if len(results) < k {
+			heap.Push(&results, neighbor{node.Sample, dist})
+		} else if dist < results[0].distance {
+			results[0] = neighbor{node.Sample, dist}
+			heap.Fix(&results, 0)
+		}
+
+		if node.Inside >= 0 {
+			bound := math.Max(entry.bound, dist-node.Radius)
+			heap.Push(queue, nodeQueueEntry{node: node.Inside, bound: bound})
+		}
+		if node.Outside >= 0 {
+			bound := math.Max(entry.bound, node.Radius-dist)
+			heap.Push(queue, nodeQueueEntry{node: node.Outside, bound: bound})
+		}
+	}
+
+	sort.Sort(sort.Reverse(results))
+	return results
+}
+
+func (v *VPTree) build(samples []Sample, m Metric, indices []int) int {
+	if len(indices) == 0 {
+		return -1
+	}
+
+	nodeIdx := len(v.Nodes)
+	v.Nodes = append(v.Nodes, VPNode{Sample: indices[0], Inside: -1, Outside: -1})
+	if len(indices) == 1 {
+		return nodeIdx
+	}
+
+	vantage := samples[indices[0]].Vector
+	rest := make([]neighbor, len(indices)-1)
+	for i, idx := range indices[1:] {
+		rest[i] = neighbor{idx, m.Distance(vantage, samples[idx].Vector)}
+	}
+	sort.Sort(neighborHeap(rest))
+
+	mid := len(rest) / 2
+	radius := rest[mid].distance
+	inside := make([]int, 0, mid)
+	outside := make([]int, 0, len(rest)-mid)
+	for _, n := range rest {
+		if n.distance < radius {
+			inside = append(inside, n.sample)
+		} else {
+			outside = append(outside, n.sample)
+		}
+	}
+
+	v.Nodes[nodeIdx].Radius = radius
+	insideIdx := v.build(samples, m, inside)
+	outsideIdx := v.build(samples, m, outside)
+	v.Nodes[nodeIdx].Inside = insideIdx
+	v.Nodes[nodeIdx].Outside = outsideIdx
+
+	return nodeIdx
+}
+
+type neighbor struct {
+	sample   int
+	distance float64
+}
+
+// neighborHeap is a max-heap of neighbors, keeping
+// the farthest current neighbor at the root.
+type neighborHeap []neighbor
+
+func (n neighborHeap) Len() int {
+	return len(n)
+}
+
+func (n neighborHeap) Less(i, j int) bool {
+	return n[i].distance > n[j].distance
+}
+
+func (n neighborHeap) Swap(i, j int) {
+	n[i], n[j] = n[j], n[i]
+}
+
+func (n *neighborHeap) Push(x interface{}) {
+	*n = append(*n, x.(neighbor))
+}
+
+func (n *neighborHeap) Pop() interface{} {
+	res := (*n)[len(*n)-1]
+	*n = (*n)[:len(*n)-1]
+	return res
+}
+
+type nodeQueueEntry struct {
+	node  int
+	bound float64
+}
+
+// nodeQueue is a min-heap of tree nodes, ordered
+// by a lower bound on the distance from the query
+// to any sample in the node's subtree.
+type nodeQueue []nodeQueueEntry
+
+func (n nodeQueue) Len() int {
+	return len(n)
+}
+
+func (n nodeQueue) Less(i, j int) bool {
+	return n[i].bound < n[j].bound
+}
+
+func (n nodeQueue) Swap(i, j int) {
+	n[i], n[j] = n[j], n[i]
+}
+
+func (n *nodeQueue) Push(x interface{}) {
+	*n = append(*n, x.(nodeQueueEntry))
+}
+
+func (n *nodeQueue) Pop() interface{} {
+	res := (*n)[len(*n)-1]
+	*n = (*n)[:len(*n)-1]
+	return res
+}
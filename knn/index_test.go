@@ -0,0 +1,64 @@
+package knn
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/whichlang/sparse"
+)
+
+func TestVPTreeExact(t *testing.T) {
+	for _, metric := range AllMetrics {
+		samples := randomSamples(metric, 300)
+		tree := NewVPTree(samples, metric, 0)
+		for i := 0; i < 30; i++ {
+			query := metric.Prepare(randomVector(20))
+			for _, k := range []int{1, 5, 20} {
+				expected := nearestMatches(samples, metric, query, k)
+				actual := tree.nearest(samples, metric, query, k)
+				if len(actual) != len(expected) {
+					t.Fatalf("%s: expected %d neighbors but got %d", metric,
+						len(expected), len(actual))
+				}
+				// Compare distances, since equidistant
+				// samples may be returned in any order.
+				for j, n := range actual {
+					if math.Abs(n.distance-expected[j].Distance) > 1e-8 {
+						t.Errorf("%s: neighbor %d of %d: expected distance %f but got %f",
+							metric, j, k, expected[j].Distance, n.distance)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// randomSamples creates samples of two languages,
+// prepared for m.
+func randomSamples(m Metric, count int) []Sample {
+	res := make([]Sample, count)
+	for i := range res {
+		vec := randomVector(20)
+		lang := "A"
+		if dense := vec.Dense(20); dense[0] > dense[1] {
+			lang = "B"
+		}
+		res[i] = Sample{Language: lang, Vector: m.Prepare(vec)}
+	}
+	return res
+}
+
+// randomVector creates a sparse vector of
+// non-negative frequencies, like those of real
+// samples.
+func randomVector(size int) sparse.Vector {
+	dense := make([]float64, size)
+	for i := range dense {
+		if rand.Float64() < 0.4 {
+			dense[i] = rand.Float64()
+		}
+	}
+	return sparse.NewVector(dense)
+}
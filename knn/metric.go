@@ -0,0 +1,96 @@
+package knn
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/unixpickle/whichlang/sparse"
+)
+
+// A Metric measures the distance between two
+// samples' frequency vectors.
+type Metric int
+
+const (
+	// CosineMetric compares vectors by the angle
+	// between them.
+	// Vectors are normalized to unit length, and
+	// the distance between them is computed as
+	// sqrt(2 - 2*cos(x, y)).
+	CosineMetric Metric = iota
+
+	// EuclideanMetric computes ||x-y||.
+	EuclideanMetric
+
+	// ManhattanMetric computes the sum of the
+	// absolute differences |x_i-y_i|.
+	ManhattanMetric
+
+	// ChiSquareMetric computes the square root of
+	// the sum of (x_i-y_i)^2/(x_i+y_i).
+	ChiSquareMetric
+)
+
+// AllMetrics contains every supported Metric.
+var AllMetrics = []Metric{CosineMetric, EuclideanMetric, ManhattanMetric, ChiSquareMetric}
+
+// Distance computes the distance between two
+// vectors which have been prepared with Prepare.
+func (m Metric) Distance(v1, v2 sparse.Vector) float64 {
+	switch m {
+	case CosineMetric:
+		return math.Sqrt(math.Max(0, 2-2*v1.Dot(v2)))
+	case EuclideanMetric:
+		var sum float64
+		v1.ForUnion(v2, func(x, y float64) {
+			sum += (x - y) * (x - y)
+		})
+		return math.Sqrt(sum)
+	case ManhattanMetric:
+		var sum float64
+		v1.ForUnion(v2, func(x, y float64) {
+			sum += math.Abs(x - y)
+		})
+		return sum
+	case ChiSquareMetric:
+		var sum float64
+		v1.ForUnion(v2, func(x, y float64) {
+			if x+y != 0 {
+				sum += (x - y) * (x - y) / (x + y)
+			}
+		})
+		return math.Sqrt(sum)
+	default:
+		panic("unknown metric: " + strconv.Itoa(int(m)))
+	}
+}
+
+// Prepare converts a frequency vector into the
+// form expected by Distance.
+// The result may share memory with v.
+func (m Metric) Prepare(v sparse.Vector) sparse.Vector {
+	if m != CosineMetric {
+		return v
+	}
+	if norm := v.Norm(); norm != 0 {
+		return v.Copy().Scale(1 / norm)
+	}
+	return v
+}
+
+// String returns the name of the metric, such
+// as "cosine" or "manhattan".
+func (m Metric) String() string {
+	switch m {
+	case CosineMetric:
+		return "cosine"
+	case EuclideanMetric:
+		return "euclidean"
+	case ManhattanMetric:
+		return "manhattan"
+	case ChiSquareMetric:
+		return "chisquare"
+	default:
+		return "Metric(" + strconv.Itoa(int(m)) + ")"
+	}
+}
@@ -0,0 +1,88 @@
+package knn
+
+// Edited returns a copy of c without the samples
+// that are misclassified by their nearest neighbors
+// among the other samples, following Wilson's
+// edited nearest neighbor rule.
+//
+// Editing removes noisy and mislabeled samples,
+// smoothing the decision boundaries.
+// If every sample would be removed, c's samples
+// are kept as they are.
+func (c *Classifier) Edited() *Classifier {
+	var kept []Sample
+	for i, sample := range c.Samples {
+		matches := nearestMatches(c.Samples, c.Metric, sample.Vector, c.NeighborCount+1)
+		for j, m := range matches {
+			if m.Index == i {
+				matches = append(matches[:j], matches[j+1:]...)
+				break
+			}
+		}
+		if len(matches) > c.NeighborCount {
+			matches = matches[:c.NeighborCount]
+		}
+		if dominantClassification(matches, c.DistanceWeighted) == sample.Language {
+			kept = append(kept, sample)
+		}
+	}
+	if len(kept) == 0 {
+		kept = c.Samples
+	}
+	return c.withSamples(kept, c.NeighborCount)
+}
+
+// Condensed returns a copy of c with a subset of
+// the samples which classifies every original
+// sample correctly under the 1-nearest neighbor
+// rule, following Hart's condensed nearest
+// neighbor algorithm.
+//
+// Condensation discards samples far from the
+// decision boundaries.
+// Since the resulting set is only consistent for
+// a single neighbor, the returned classifier
+// has a NeighborCount of 1.
+func (c *Classifier) Condensed() *Classifier {
+	if len(c.Samples) == 0 {
+		return c.withSamples(nil, 1)
+	}
+
+	stored := make([]bool, len(c.Samples))
+	stored[0] = true
+	store := []Sample{c.Samples[0]}
+
+	for changed := true; changed; {
+		changed = false
+		for i, sample := range c.Samples {
+			if stored[i] {
+				continue
+			}
+			nearest := nearestMatches(store, c.Metric, sample.Vector, 1)
+			if nearest[0].Language != sample.Language {
+				stored[i] = true
+				store = append(store, sample)
+				changed = true
+			}
+		}
+	}
+
+	return c.withSamples(store, 1)
+}
+
+// withSamples creates a copy of c with new samples
+// and a new neighbor count, rebuilding the index
+// if c has one.
+func (c *Classifier) withSamples(s []Sample, neighborCount int) *Classifier {
+	res := &Classifier{
+		Tokens:           c.Tokens,
+		Samples:          s,
+		NeighborCount:    neighborCount,
+		Metric:           c.Metric,
+		DistanceWeighted: c.DistanceWeighted,
+	}
+	if c.Index != nil {
+		res.Index = NewVPTree(s, c.Metric, c.Index.MaxChecks)
+	}
+	return res
+}
@@ -0,0 +1,22 @@
+package knn
+
+import "testing"
+
+func TestCondensedConsistent(t *testing.T) {
+	samples := randomSamples(EuclideanMetric, 200)
+	c := &Classifier{
+		Samples:       samples,
+		NeighborCount: 3,
+		Metric:        EuclideanMetric,
+	}
+	condensed := c.Condensed()
+	if len(condensed.Samples) >= len(samples) {
+		t.Errorf("expected fewer than %d prototypes but got %d", len(samples),
+			len(condensed.Samples))
+	}
+	for i, sample := range samples {
+		if actual := condensed.classifyVector(sample.Vector); actual != sample.Language {
+			t.Errorf("sample %d: expected %s but got %s", i, sample.Language, actual)
+		}
+	}
+}
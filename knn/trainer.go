@@ -4,16 +4,28 @@ import (
 	"math/rand"
 	"sort"
 
-	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/whichlang/sparse"
 	"github.com/unixpickle/whichlang/tokens"
 )
 
 // crossValidationFrac specifies the fraction of
 // samples which are used for cross-validation
-// when determining the optimal k-value.
+// when determining the optimal k-value and metric.
 const crossValidationFrac = 0.3
 
+// Train generates a Classifier using parameters
+// from EnvTrainerParams.
 func Train(f map[string][]tokens.Freqs) *Classifier {
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(f, params)
+}
+
+// TrainParams generates a Classifier for the
+// samples using the given parameters.
+func TrainParams(f map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
 	seenToks := map[string]bool{}
 	sampleCount := 0
 	for _, samples := range f {
@@ -26,75 +38,96 @@ func Train(f map[string][]tokens.Freqs) *Classifier {
 	}
 
 	toks := make([]string, 0, len(seenToks))
+	tokIndices := make(map[string]int, len(seenToks))
 	for tok := range seenToks {
+		tokIndices[tok] = len(toks)
 		toks = append(toks, tok)
 	}
 
 	samples := make([]Sample, 0, sampleCount)
 	for lang, freqSamples := range f {
-		for _, freqs := range freqSamples {
-			vec := make(linalg.Vector, len(toks))
-			for i, token := range toks {
-				vec[i] = freqs[token]
+		sources := p.Sources[lang]
+		for i, freqs := range freqSamples {
+			sample := Sample{
+				Language: lang,
+				Vector:   sparse.FreqsVector(tokIndices, freqs),
 			}
-			if mag := vec.Dot(vec); mag != 0 {
-				vec.Scale(1 / mag)
+			if i < len(sources) {
+				sample.Source = sources[i]
 			}
-			samples = append(samples, Sample{
-				Language: lang,
-				Vector:   vec,
-			})
+			samples = append(samples, sample)
 		}
 	}
 
-	kValue := optimalKValue(samples)
-	return &Classifier{
-		Tokens:        toks,
-		Samples:       samples,
-		NeighborCount: kValue,
+	kValue, metric := optimalKValue(samples, p.Metrics, p.DistanceWeighted)
+	res := &Classifier{
+		Tokens:           toks,
+		Samples:          prepareSamples(samples, metric),
+		NeighborCount:    kValue,
+		Metric:           metric,
+		DistanceWeighted: p.DistanceWeighted,
 	}
+	if p.Edit {
+		res = res.Edited()
+	}
+	if p.Condense {
+		res = res.Condensed()
+	}
+	if p.Index {
+		res.Index = NewVPTree(res.Samples, metric, p.MaxChecks)
+	}
+	return res
 }
 
-func optimalKValue(s []Sample) int {
+// optimalKValue uses cross-validation to choose
+// the best k-value and metric for the samples,
+// which should contain raw frequency vectors.
+func optimalKValue(s []Sample, metrics []Metric, weighted bool) (int, Metric) {
 	crossCount := int(crossValidationFrac * float64(len(s)))
 	if crossCount == 0 {
-		return 1
+		return 1, metrics[0]
 	}
 	samples := shuffleSamples(s)
-	crossSamples := samples[0:crossCount]
-	trainingSamples := samples[crossCount:]
-
-	crossMatches := sortedCrossMatches(crossSamples, trainingSamples)
 
 	bestK := 1
+	bestMetric := metrics[0]
 	bestCorrect := 0
-	for k := 1; k <= len(trainingSamples); k++ {
-		crossCorrect := 0
-		for crossIdx, matches := range crossMatches {
-			classification := dominantClassification(matches[:k])
-			actualLang := crossSamples[crossIdx].Language
-			if classification == actualLang {
-				crossCorrect++
+	for _, metric := range metrics {
+		prepared := prepareSamples(samples, metric)
+		crossSamples := prepared[0:crossCount]
+		trainingSamples := prepared[crossCount:]
+
+		crossMatches := sortedCrossMatches(crossSamples, trainingSamples, metric)
+
+		for k := 1; k <= len(trainingSamples); k++ {
+			crossCorrect := 0
+			for crossIdx, matches := range crossMatches {
+				classification := dominantClassification(matches[:k], weighted)
+				actualLang := crossSamples[crossIdx].Language
+				if classification == actualLang {
+					crossCorrect++
+				}
+			}
+			if crossCorrect > bestCorrect {
+				bestK = k
+				bestMetric = metric
+				bestCorrect = crossCorrect
 			}
-		}
-		if crossCorrect > bestCorrect {
-			bestK = k
-			bestCorrect = crossCorrect
 		}
 	}
 
-	return bestK
+	return bestK, bestMetric
 }
 
-func sortedCrossMatches(cross, training []Sample) [][]match {
+func sortedCrossMatches(cross, training []Sample, m Metric) [][]match {
 	res := make([][]match, len(cross))
 	for i, crossSample := range cross {
 		res[i] = make([]match, len(training))
 		for j, trainingSample := range training {
-			correlation := trainingSample.Vector.Dot(crossSample.Vector)
 			res[i][j] = match{
-				Language:    trainingSample.Language,
-				Correlation: correlation,
+				Language: trainingSample.Language,
+				Distance: m.Distance(trainingSample.Vector, crossSample.Vector),
+				Index:    j,
 			}
 		}
 		sort.Sort(matchSorter(res[i]))
@@ -102,6 +135,20 @@ func sortedCrossMatches(cross, training []Sample) [][]match {
 	return res
 }
 
+// prepareSamples applies m.Prepare to a copy
+// of the samples.
+func prepareSamples(s []Sample, m Metric) []Sample {
+	res := make([]Sample, len(s))
+	for i, sample := range s {
+		res[i] = Sample{
+			Language: sample.Language,
+			Vector:   m.Prepare(sample.Vector),
+			Source:   sample.Source,
+		}
+	}
+	return res
+}
+
 func shuffleSamples(s []Sample) []Sample {
 	res := make([]Sample, len(s))
 
@@ -120,7 +167,7 @@ func (m matchSorter) Len() int {
 }
 
 func (m matchSorter) Less(i, j int) bool {
-	return m[i].Correlation > m[j].Correlation
+	return m[i].Distance < m[j].Distance
 }
 
 func (m matchSorter) Swap(i, j int) {
@@ -0,0 +1,113 @@
+package knn
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+// These environment variables specify
+// various parameters for the KNN trainer.
+const (
+	// Set this to "none" to train a classifier
+	// without a search index, so that every query
+	// does an exact linear scan of the samples.
+	// The default, "vptree", builds a VPTree.
+	IndexEnvVar = "KNN_INDEX"
+
+	// The maximum number of distances computed
+	// when searching the index for a query.
+	// The default, 0, makes searches exact.
+	MaxChecksEnvVar = "KNN_MAX_CHECKS"
+
+	// You may set this to "cosine", "euclidean",
+	// "manhattan", or "chisquare".
+	// By default, every metric is tried and the
+	// best is chosen by cross-validation.
+	MetricEnvVar = "KNN_METRIC"
+
+	// Set this to "1" to weight each neighbor's
+	// vote by the inverse of its distance.
+	DistanceWeightedEnvVar = "KNN_DISTANCE_WEIGHTED"
+
+	// Set this to "1" to remove noisy samples
+	// with Classifier.Edited after training.
+	EditEnvVar = "KNN_EDIT"
+
+	// Set this to "1" to shrink the classifier
+	// with Classifier.Condensed after training.
+	// If editing is also enabled, it is done
+	// before condensing.
+	CondenseEnvVar = "KNN_CONDENSE"
+)
+
+// TrainerParams specifies parameters for the
+// KNN trainer.
+type TrainerParams struct {
+	// Index specifies whether or not to build
+	// a VPTree for the classifier.
+	Index bool
+
+	// MaxChecks is used for VPTree.MaxChecks.
+	MaxChecks int
+
+	// Metrics lists the metrics to choose from
+	// during cross-validation.
+	Metrics []Metric
+
+	DistanceWeighted bool
+
+	Edit     bool
+	Condense bool
+
+	// Sources optionally identifies the file for
+	// each training sample, mapping languages to
+	// lists which parallel the training samples.
+	// It is used to set Sample.Source.
+	Sources map[string][]string
+}
+
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := TrainerParams{
+		Index:            true,
+		Metrics:          AllMetrics,
+		DistanceWeighted: os.Getenv(DistanceWeightedEnvVar) == "1",
+		Edit:             os.Getenv(EditEnvVar) == "1",
+		Condense:         os.Getenv(CondenseEnvVar) == "1",
+	}
+
+	switch val := os.Getenv(IndexEnvVar); val {
+	case "", "vptree":
+	case "none":
+		res.Index = false
+	default:
+		return nil, errors.New("unknown index: " + val)
+	}
+
+	if val := os.Getenv(MaxChecksEnvVar); val != "" {
+		checks, err := strconv.Atoi(val)
+		if err != nil || checks < 0 {
+			return nil, errors.New("invalid max checks: " + val)
+		}
+		res.MaxChecks = checks
+	}
+
+	if val := os.Getenv(MetricEnvVar); val != "" {
+		found := false
+		for _, metric := range AllMetrics {
+			if metric.String() == val {
+				res.Metrics = []Metric{metric}
+				found = true
+			}
+		}
+		if !found {
+			return nil, errors.New("unknown metric: " + val)
+		}
+	}
+
+	return &res, nil
+}
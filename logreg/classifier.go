@@ -0,0 +1,171 @@
+// Package logreg implements multinomial logistic
+// regression (also known as maximum entropy
+// classification) on token frequencies.
+package logreg
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// A Classifier scores each language as a bias plus
+// a weighted sum of token frequencies, and turns
+// the scores into probabilities with a softmax.
+type Classifier struct {
+	Tokens []string
+	Langs  []string
+
+	Biases []float64
+
+	// Weights stores a weight for each token for
+	// each language, indexed like Langs and Tokens.
+	// Tokens whose weights were all zero after
+	// training are not stored.
+	Weights [][]float64
+
+	tokenIndicesOnce sync.Once
+	tokenIndices     map[string]int
+}
+
+func DecodeClassifier(d []byte) (*Classifier, error) {
+	var c Classifier
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Biases) != len(c.Langs) || len(c.Weights) != len(c.Langs) {
+		return nil, errors.New("mismatched language count")
+	}
+	for _, w := range c.Weights {
+		if len(w) != len(c.Tokens) {
+			return nil, errors.New("mismatched token count")
+		}
+	}
+	return &c, nil
+}
+
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	scores := c.scores(f)
+	var bestIdx int
+	for i, score := range scores {
+		if score > scores[bestIdx] {
+			bestIdx = i
+		}
+	}
+	return c.Langs[bestIdx]
+}
+
+// Probabilities returns the probability of each
+// language according to the model.
+func (c *Classifier) Probabilities(f tokens.Freqs) map[string]float64 {
+	scores := c.scores(f)
+	softmax(scores)
+	res := map[string]float64{}
+	for i, lang := range c.Langs {
+		res[lang] = scores[i]
+	}
+	return res
+}
+
+// Ranked returns the languages sorted from most to
+// least probable, along with their probabilities.
+func (c *Classifier) Ranked(f tokens.Freqs) ([]string, []float64) {
+	scores := c.scores(f)
+	softmax(scores)
+	s := &langSorter{
+		langs: append([]string{}, c.Langs...),
+		probs: scores,
+	}
+	sort.Sort(s)
+	return s.langs, s.probs
+}
+
+// TokenWeights returns the non-zero weight of every
+// token for a language.
+// Positive weights are evidence for the language,
+// and negative weights are evidence against it.
+func (c *Classifier) TokenWeights(lang string) map[string]float64 {
+	res := map[string]float64{}
+	for i, l := range c.Langs {
+		if l != lang {
+			continue
+		}
+		for j, w := range c.Weights[i] {
+			if w != 0 {
+				res[c.Tokens[j]] = w
+			}
+		}
+	}
+	return res
+}
+
+func (c *Classifier) Encode() []byte {
+	res, _ := json.Marshal(c)
+	return res
+}
+
+func (c *Classifier) Languages() []string {
+	return c.Langs
+}
+
+func (c *Classifier) scores(f tokens.Freqs) []float64 {
+	c.tokenIndicesOnce.Do(func() {
+		c.tokenIndices = map[string]int{}
+		for i, token := range c.Tokens {
+			c.tokenIndices[token] = i
+		}
+	})
+
+	res := make([]float64, len(c.Langs))
+	copy(res, c.Biases)
+	for token, freq := range f {
+		idx, ok := c.tokenIndices[token]
+		if !ok || freq == 0 {
+			continue
+		}
+		for i, weights := range c.Weights {
+			res[i] += freq * weights[idx]
+		}
+	}
+	return res
+}
+
+// softmax replaces scores with their softmax and
+// returns the log of the normalizing constant.
+func softmax(scores []float64) float64 {
+	max := math.Inf(-1)
+	for _, score := range scores {
+		max = math.Max(max, score)
+	}
+	var expSum float64
+	for i, score := range scores {
+		scores[i] = math.Exp(score - max)
+		expSum += scores[i]
+	}
+	for i := range scores {
+		scores[i] /= expSum
+	}
+	return max + math.Log(expSum)
+}
+
+type langSorter struct {
+	langs []string
+	probs []float64
+}
+
+func (l *langSorter) Len() int {
+	return len(l.langs)
+}
+
+func (l *langSorter) Less(i, j int) bool {
+	return l.probs[i] > l.probs[j]
+}
+
+func (l *langSorter) Swap(i, j int) {
+	l.langs[i], l.langs[j] = l.langs[j], l.langs[i]
+	l.probs[i], l.probs[j] = l.probs[j], l.probs[i]
+}
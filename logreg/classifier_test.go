@@ -0,0 +1,64 @@
+package logreg
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func TestL1Sparsity(t *testing.T) {
+	// Each language has one token of its own, and
+	// the noise tokens are equally likely in both.
+	r := rand.New(rand.NewSource(1))
+	data := map[string][]tokens.Freqs{}
+	for _, lang := range []string{"A", "B"} {
+		for i := 0; i < 30; i++ {
+			counts := tokens.Counts{"only" + lang: 5}
+			for j := 0; j < 5; j++ {
+				counts["noise"+strconv.Itoa(j)] = r.Intn(5) + 1
+			}
+			data[lang] = append(data[lang], counts.Freqs())
+		}
+	}
+
+	p := &TrainerParams{L2s: []float64{1e-4}, MaxIterations: 1000, Tolerance: 1e-9}
+	dense := TrainParams(data, p)
+	p.L1 = 1e-2
+	pruned, err := DecodeClassifier(TrainParams(data, p).Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var denseNoise bool
+	for _, lang := range []string{"A", "B"} {
+		sparseWeights := pruned.TokenWeights(lang)
+		denseWeights := dense.TokenWeights(lang)
+		for j := 0; j < 5; j++ {
+			token := "noise" + strconv.Itoa(j)
+			if w := sparseWeights[token]; w != 0 {
+				t.Errorf("%s: expected zero weight for %s but got %f", lang, token, w)
+			}
+			if denseWeights[token] != 0 {
+				denseNoise = true
+			}
+		}
+		if w := sparseWeights["only"+lang]; w <= 0 {
+			t.Errorf("%s: expected positive weight for its token but got %f", lang, w)
+		}
+	}
+	if !denseNoise {
+		t.Error("expected noise weights without L1")
+	}
+
+	query := tokens.Counts{"onlyA": 1, "noise0": 3}.Freqs()
+	if actual := pruned.Classify(query); actual != "A" {
+		t.Errorf("expected A but got %s", actual)
+	}
+	probs := pruned.Probabilities(query)
+	if sum := probs["A"] + probs["B"]; math.Abs(sum-1) > 1e-8 {
+		t.Errorf("probabilities sum to %f", sum)
+	}
+}
@@ -0,0 +1,360 @@
+package logreg
+
+import (
+	"log"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/unixpickle/whichlang/sparse"
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// Train trains a Classifier using TrainerParams
+// from EnvTrainerParams.
+func Train(data map[string][]tokens.Freqs) *Classifier {
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(data, params)
+}
+
+// TrainParams trains a Classifier on the data.
+// If p has more than one L2 coefficient, the one
+// which does best on withheld samples is used to
+// train the final Classifier on all of the data.
+func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
+	bestL2 := p.L2s[0]
+	if len(p.L2s) > 1 {
+		training, cross := splitSamples(data, p.CrossValidation)
+		bestScore := -1.0
+		for _, l2 := range p.L2s {
+			if p.Verbose {
+				log.Printf("Trying L2 coefficient %e", l2)
+			}
+			score := correctFraction(train(training, p, l2), cross)
+			if p.Verbose {
+				log.Printf("Results: cross=%f", score)
+			}
+			if score > bestScore {
+				bestScore = score
+				bestL2 = l2
+			}
+		}
+	}
+
+	if p.Verbose {
+		log.Printf("Training final classifier with L2 coefficient %e", bestL2)
+	}
+	res := train(data, p, bestL2)
+	if p.Verbose {
+		log.Printf("Results: training=%f tokens=%d", correctFraction(res, data),
+			len(res.Tokens))
+	}
+	return res
+}
+
+func train(data map[string][]tokens.Freqs, p *TrainerParams, l2 float64) *Classifier {
+	t := newTrainer(data, p.L1, l2)
+
+	// Minimize the objective with FISTA, the
+	// accelerated proximal gradient method, using
+	// backtracking to find the step size.
+	params := make([]float64, len(t.langs)*t.stride)
+	momentum := make([]float64, len(params))
+	grad := make([]float64, len(params))
+	next := make([]float64, len(params))
+	lipschitz := 1.0
+	acceleration := 1.0
+	lastObjective := math.Inf(1)
+	for i := 0; i < p.MaxIterations; i++ {
+		loss := t.loss(momentum, grad)
+		var nextLoss float64
+		for {
+			for j, x := range momentum {
+				next[j] = x - grad[j]/lipschitz
+			}
+			t.shrink(next, t.l1/lipschitz)
+			nextLoss = t.loss(next, nil)
+			var linear, quadratic float64
+			for j, x := range next {
+				diff := x - momentum[j]
+				linear += grad[j] * diff
+				quadratic += diff * diff
+			}
+			if nextLoss <= loss+linear+lipschitz*quadratic/2 {
+				break
+			}
+			lipschitz *= 2
+		}
+		lipschitz /= 2
+
+		nextAcceleration := (1 + math.Sqrt(1+4*acceleration*acceleration)) / 2
+		for j, x := range next {
+			momentum[j] = x + (acceleration-1)/nextAcceleration*(x-params[j])
+		}
+		acceleration = nextAcceleration
+		params, next = next, params
+
+		objective := nextLoss + t.l1Penalty(params)
+		if p.Verbose && i%10 == 0 {
+			log.Printf("iteration %d: objective=%f", i, objective)
+		}
+		if math.Abs(lastObjective-objective) <= p.Tolerance*math.Max(1, math.Abs(objective)) {
+			break
+		}
+		lastObjective = objective
+	}
+
+	return t.classifier(params)
+}
+
+type trainingSample struct {
+	vec  sparse.Vector
+	lang int
+}
+
+// A trainer computes the regularized loss of the
+// model on a set of samples.
+//
+// The parameters of the model are stored in one
+// slice, with stride entries per language: a weight
+// for each token, followed by the bias.
+// The weights apply to token frequencies divided by
+// their root-mean-square, so that a single step
+// size and penalty suit common and rare tokens.
+type trainer struct {
+	tokens  []string
+	langs   []string
+	scales  []float64
+	samples []trainingSample
+
+	stride int
+	l1     float64
+	l2     float64
+}
+
+func newTrainer(data map[string][]tokens.Freqs, l1, l2 float64) *trainer {
+	res := &trainer{l1: l1, l2: l2}
+	tokenSet := map[string]bool{}
+	for lang, samples := range data {
+		res.langs = append(res.langs, lang)
+		for _, sample := range samples {
+			for token := range sample {
+				tokenSet[token] = true
+			}
+		}
+	}
+	for token := range tokenSet {
+		res.tokens = append(res.tokens, token)
+	}
+	sort.Strings(res.langs)
+	sort.Strings(res.tokens)
+	res.stride = len(res.tokens) + 1
+
+	tokenIndices := map[string]int{}
+	for i, token := range res.tokens {
+		tokenIndices[token] = i
+	}
+	sumSquares := make([]float64, len(res.tokens))
+	for langIdx, lang := range res.langs {
+		for _, sample := range data[lang] {
+			vec := sparse.FreqsVector(tokenIndices, sample)
+			for i, idx := range vec.Indices {
+				sumSquares[idx] += vec.Values[i] * vec.Values[i]
+			}
+			res.samples = append(res.samples, trainingSample{vec: vec, lang: langIdx})
+		}
+	}
+	res.scales = make([]float64, len(res.tokens))
+	for i, sum := range sumSquares {
+		res.scales[i] = 1
+		if sum > 0 {
+			res.scales[i] = math.Sqrt(float64(len(res.samples)) / sum)
+		}
+	}
+	for _, s := range res.samples {
+		for i, idx := range s.vec.Indices {
+			s.vec.Values[i] *= res.scales[idx]
+		}
+	}
+	return res
+}
+
+// loss computes the mean cross-entropy loss plus the
+// L2 penalty.
+// If grad is non-nil, it is set to the gradient.
+func (t *trainer) loss(params, grad []float64) float64 {
+	// errs[i][j] is the derivative of sample i's
+	// loss with respect to its score for language j.
+	errs := make([][]float64, len(t.samples))
+	losses := make([]float64, len(t.samples))
+	parallelFor(len(t.samples), func(i int) {
+		s := t.samples[i]
+		scores := make([]float64, len(t.langs))
+		for j := range scores {
+			weights := params[j*t.stride : (j+1)*t.stride]
+			scores[j] = weights[len(t.tokens)] + s.vec.DotDense(weights)
+		}
+		correctScore := scores[s.lang]
+		losses[i] = softmax(scores) - correctScore
+		scores[s.lang]--
+		errs[i] = scores
+	})
+
+	var loss, penalty float64
+	for _, l := range losses {
+		loss += l
+	}
+	loss /= float64(len(t.samples))
+	for j := range t.langs {
+		for _, w := range params[j*t.stride : (j+1)*t.stride-1] {
+			penalty += w * w
+		}
+	}
+	loss += t.l2 * penalty / 2
+
+	if grad != nil {
+		scale := 1 / float64(len(t.samples))
+		parallelFor(len(t.langs), func(j int) {
+			g := grad[j*t.stride : (j+1)*t.stride]
+			weights := params[j*t.stride : (j+1)*t.stride]
+			for k := range g {
+				g[k] = 0
+			}
+			for i, s := range t.samples {
+				e := errs[i][j] * scale
+				for k, idx := range s.vec.Indices {
+					g[idx] += e * s.vec.Values[k]
+				}
+				g[len(t.tokens)] += e
+			}
+			for k, w := range weights[:len(t.tokens)] {
+				g[k] += t.l2 * w
+			}
+		})
+	}
+
+	return loss
+}
+
+// shrink applies the proximal operator of the L1
+// penalty (soft thresholding) to the weights, but
+// not the biases.
+func (t *trainer) shrink(params []float64, threshold float64) {
+	if threshold == 0 {
+		return
+	}
+	for j := range t.langs {
+		for k, w := range params[j*t.stride : (j+1)*t.stride-1] {
+			idx := j*t.stride + k
+			if w > threshold {
+				params[idx] = w - threshold
+			} else if w < -threshold {
+				params[idx] = w + threshold
+			} else {
+				params[idx] = 0
+			}
+		}
+	}
+}
+
+func (t *trainer) l1Penalty(params []float64) float64 {
+	var res float64
+	for j := range t.langs {
+		for _, w := range params[j*t.stride : (j+1)*t.stride-1] {
+			res += math.Abs(w)
+		}
+	}
+	return res * t.l1
+}
+
+// classifier creates a Classifier from the
+// parameters, dropping tokens with no non-zero
+// weights.
+func (t *trainer) classifier(params []float64) *Classifier {
+	res := &Classifier{
+		Langs:   t.langs,
+		Weights: make([][]float64, len(t.langs)),
+	}
+	for j := range t.langs {
+		res.Biases = append(res.Biases, params[(j+1)*t.stride-1])
+	}
+	for k, token := range t.tokens {
+		var used bool
+		for j := range t.langs {
+			if params[j*t.stride+k] != 0 {
+				used = true
+				break
+			}
+		}
+		if !used {
+			continue
+		}
+		res.Tokens = append(res.Tokens, token)
+		for j := range t.langs {
+			res.Weights[j] = append(res.Weights[j], params[j*t.stride+k]*t.scales[k])
+		}
+	}
+	return res
+}
+
+// parallelFor calls f for every integer in [0, n)
+// using one goroutine per CPU.
+func parallelFor(n int, f func(i int)) {
+	indices := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// splitSamples randomly withholds a fraction of each
+// language's samples.
+func splitSamples(data map[string][]tokens.Freqs,
+	fraction float64) (training, cross map[string][]tokens.Freqs) {
+	training = map[string][]tokens.Freqs{}
+	cross = map[string][]tokens.Freqs{}
+	for lang, samples := range data {
+		numCross := int(float64(len(samples)) * fraction)
+		for i, idx := range rand.Perm(len(samples)) {
+			if i < numCross {
+				cross[lang] = append(cross[lang], samples[idx])
+			} else {
+				training[lang] = append(training[lang], samples[idx])
+			}
+		}
+	}
+	return
+}
+
+func correctFraction(c *Classifier, data map[string][]tokens.Freqs) float64 {
+	var correct, total int
+	for lang, samples := range data {
+		for _, sample := range samples {
+			if c.Classify(sample) == lang {
+				correct++
+			}
+			total++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(correct) / float64(total)
+}
@@ -0,0 +1,103 @@
+package logreg
+
+import (
+	"errors"
+	"os"
+	"strconv"
+)
+
+const (
+	defaultCrossValidationFraction = 0.3
+	defaultMaxIterations           = 500
+	defaultTolerance               = 1e-6
+)
+
+var defaultL2s = []float64{1e-4, 1e-3, 1e-2, 1e-1}
+
+// These environment variables specify
+// various parameters for the trainer.
+const (
+	// Set this to "1" to get verbose logs.
+	VerboseEnvVar = "LOGREG_VERBOSE"
+
+	// The coefficient of the L1 penalty on the
+	// weights, which drives the weights of unhelpful
+	// tokens to exactly zero.
+	// The default is 0.
+	L1EnvVar = "LOGREG_L1"
+
+	// The coefficient of the L2 penalty on the
+	// weights.
+	// By default, several values are tried.
+	L2EnvVar = "LOGREG_L2"
+
+	// The fraction (from 0-1) of samples which are
+	// used for cross validation when choosing the
+	// L2 coefficient.
+	CrossValidationEnvVar = "LOGREG_CROSS_VALIDATION"
+
+	// The maximum number of gradient steps.
+	MaxIterationsEnvVar = "LOGREG_MAX_ITERATIONS"
+)
+
+// TrainerParams specifies parameters for the
+// logistic regression trainer.
+type TrainerParams struct {
+	Verbose bool
+
+	L1  float64
+	L2s []float64
+
+	CrossValidation float64
+
+	// MaxIterations and Tolerance decide when to
+	// stop training.
+	// Training stops early once the objective changes
+	// by less than Tolerance (relative to its value)
+	// in one step.
+	MaxIterations int
+	Tolerance     float64
+}
+
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := &TrainerParams{
+		Verbose:         os.Getenv(VerboseEnvVar) == "1",
+		L2s:             defaultL2s,
+		CrossValidation: defaultCrossValidationFraction,
+		MaxIterations:   defaultMaxIterations,
+		Tolerance:       defaultTolerance,
+	}
+	if val := os.Getenv(L1EnvVar); val != "" {
+		l1, err := strconv.ParseFloat(val, 64)
+		if err != nil || l1 < 0 {
+			return nil, errors.New("invalid L1 coefficient: " + val)
+		}
+		res.L1 = l1
+	}
+	if val := os.Getenv(L2EnvVar); val != "" {
+		l2, err := strconv.ParseFloat(val, 64)
+		if err != nil || l2 < 0 {
+			return nil, errors.New("invalid L2 coefficient: " + val)
+		}
+		res.L2s = []float64{l2}
+	}
+	if val := os.Getenv(CrossValidationEnvVar); val != "" {
+		frac, err := strconv.ParseFloat(val, 64)
+		if err != nil || frac <= 0 || frac >= 1 {
+			return nil, errors.New("invalid cross validation fraction: " + val)
+		}
+		res.CrossValidation = frac
+	}
+	if val := os.Getenv(MaxIterationsEnvVar); val != "" {
+		iters, err := strconv.Atoi(val)
+		if err != nil || iters < 1 {
+			return nil, errors.New("invalid max iterations: " + val)
+		}
+		res.MaxIterations = iters
+	}
+	return res, nil
+}
@@ -3,10 +3,19 @@
 package whichlang
 
 import (
+	"io/ioutil"
+
+	"github.com/unixpickle/whichlang/centroid"
+	"github.com/unixpickle/whichlang/charlm"
+	"github.com/unixpickle/whichlang/fasttext"
 	"github.com/unixpickle/whichlang/gaussbayes"
 	"github.com/unixpickle/whichlang/idtree"
 	"github.com/unixpickle/whichlang/knn"
+	"github.com/unixpickle/whichlang/logreg"
+	"github.com/unixpickle/whichlang/naivebayes"
+	"github.com/unixpickle/whichlang/ncd"
 	"github.com/unixpickle/whichlang/neuralnet"
+	"github.com/unixpickle/whichlang/perceptron"
 	"github.com/unixpickle/whichlang/svm"
 	"github.com/unixpickle/whichlang/tokens"
 )
@@ -31,17 +40,59 @@ type Classifier interface {
 	Encode() []byte
 }
 
+// A TextClassifier is a Classifier which can also
+// classify raw text, which is more accurate than
+// classifying its tokens.
+type TextClassifier interface {
+	Classifier
+
+	ClassifyText(text string) string
+}
+
 // A Trainer generates a Classifier using
 // a collection of tokenized sample files.
 type Trainer func(map[string][]tokens.Freqs) Classifier
 
+// A SourceTrainer is like a Trainer, but it also
+// receives the file behind each sample, in lists
+// which parallel the samples.
+type SourceTrainer func(freqs map[string][]tokens.Freqs,
+	sources map[string][]string) (Classifier, error)
+
+// A CountTrainer is like a Trainer, but it
+// receives the token counts of each sample rather
+// than frequencies.
+type CountTrainer func(tokens.SampleCounts) (Classifier, error)
+
+// A ResumableTrainer loads the state which an
+// interrupted training run saved to a file, and
+// returns a Trainer which continues that run.
+type ResumableTrainer func(path string) (Trainer, error)
+
+// A SampleSource opens a new reader over the same
+// samples every time it is called.
+type SampleSource func() (tokens.SampleReader, error)
+
+// A StreamTrainer generates a Classifier from
+// samples which it reads as it needs them, rather
+// than all at once.
+// If resumePath is not "", training continues from
+// the classifier saved there.
+type StreamTrainer func(s SampleSource, resumePath string) (Classifier, error)
+
+// A TextTrainer generates a Classifier from the
+// raw text of sample files, read one at a time.
+type TextTrainer func(tokens.TextReader) (Classifier, error)
+
 // A Decoder decodes a certain type of
 // Classifier from binary data.
 type Decoder func(d []byte) (Classifier, error)
 
 // ClassifierNames is an array containing the
 // names of every supported classifier.
-var ClassifierNames = []string{"idtree", "neuralnet", "knn", "svm", "gaussbayes"}
+var ClassifierNames = []string{"idtree", "neuralnet", "knn", "svm", "gaussbayes",
+	"multinomialnb", "bernoullinb", "complementnb", "logreg", "perceptron", "centroid",
+	"charlm", "ncd", "fasttext"}
 
 // Trainers maps classifier names to their
 // corresponding Trainers.
@@ -61,6 +112,145 @@ var Trainers = map[string]Trainer{
 	"gaussbayes": func(freqs map[string][]tokens.Freqs) Classifier {
 		return gaussbayes.Train(freqs)
 	},
+	"multinomialnb": func(freqs map[string][]tokens.Freqs) Classifier {
+		return naivebayes.Train(freqs, naivebayes.Multinomial)
+	},
+	"bernoullinb": func(freqs map[string][]tokens.Freqs) Classifier {
+		return naivebayes.Train(freqs, naivebayes.Bernoulli)
+	},
+	"complementnb": func(freqs map[string][]tokens.Freqs) Classifier {
+		return naivebayes.Train(freqs, naivebayes.Complement)
+	},
+	"logreg": func(freqs map[string][]tokens.Freqs) Classifier {
+		return logreg.Train(freqs)
+	},
+	"perceptron": func(freqs map[string][]tokens.Freqs) Classifier {
+		return perceptron.Train(freqs)
+	},
+	"centroid": func(freqs map[string][]tokens.Freqs) Classifier {
+		return centroid.Train(freqs)
+	},
+	"charlm": func(freqs map[string][]tokens.Freqs) Classifier {
+		return charlm.Train(freqs)
+	},
+	"ncd": func(freqs map[string][]tokens.Freqs) Classifier {
+		return ncd.Train(freqs)
+	},
+	"fasttext": func(freqs map[string][]tokens.Freqs) Classifier {
+		return fasttext.Train(freqs)
+	},
+}
+
+// SourceTrainers maps the names of classifiers
+// which record where their samples came from to
+// their SourceTrainers.
+// Trainers still has an entry for each of them.
+var SourceTrainers = map[string]SourceTrainer{
+	"knn": func(freqs map[string][]tokens.Freqs,
+		sources map[string][]string) (Classifier, error) {
+		params, err := knn.EnvTrainerParams()
+		if err != nil {
+			return nil, err
+		}
+		params.Sources = sources
+		return knn.TrainParams(freqs, params), nil
+	},
+}
+
+// CountTrainers maps the names of classifiers
+// which model token counts to their CountTrainers.
+// Trainers still has an entry for each of them,
+// which assumes a fixed document length.
+var CountTrainers = map[string]CountTrainer{
+	"multinomialnb": func(counts tokens.SampleCounts) (Classifier, error) {
+		return trainNaiveBayesCounts(counts, naivebayes.Multinomial)
+	},
+	"bernoullinb": func(counts tokens.SampleCounts) (Classifier, error) {
+		return trainNaiveBayesCounts(counts, naivebayes.Bernoulli)
+	},
+	"complementnb": func(counts tokens.SampleCounts) (Classifier, error) {
+		return trainNaiveBayesCounts(counts, naivebayes.Complement)
+	},
+}
+
+// ResumableTrainers maps the names of classifiers
+// whose training can be resumed to their
+// ResumableTrainers.
+var ResumableTrainers = map[string]ResumableTrainer{
+	"neuralnet": func(path string) (Trainer, error) {
+		checkpoint, err := neuralnet.ReadCheckpoint(path)
+		if err != nil {
+			return nil, err
+		}
+		params, err := neuralnet.EnvTrainerParams()
+		if err != nil {
+			return nil, err
+		}
+		// Unless another checkpoint file is specified,
+		// new checkpoints overwrite the old one.
+		if params.CheckpointPath == "" {
+			params.CheckpointPath = path
+		}
+		return func(freqs map[string][]tokens.Freqs) Classifier {
+			return neuralnet.Resume(freqs, checkpoint, params)
+		}, nil
+	},
+}
+
+// StreamTrainers maps the names of classifiers
+// which can learn from one sample at a time to
+// their StreamTrainers.
+// Trainers still has an entry for each of them.
+var StreamTrainers = map[string]StreamTrainer{
+	"perceptron": func(s SampleSource, resumePath string) (Classifier, error) {
+		params, err := perceptron.EnvTrainerParams()
+		if err != nil {
+			return nil, err
+		}
+		if resumePath == "" {
+			return perceptron.TrainStream(s, params)
+		}
+		data, err := ioutil.ReadFile(resumePath)
+		if err != nil {
+			return nil, err
+		}
+		classifier, err := perceptron.DecodeClassifier(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := classifier.UpdatePasses(s, params.Passes); err != nil {
+			return nil, err
+		}
+		return classifier, nil
+	},
+}
+
+// TextTrainers maps the names of classifiers which
+// work best on raw text to their TextTrainers.
+// Trainers still has an entry for each of them,
+// which approximates the text from tokens.
+var TextTrainers = map[string]TextTrainer{
+	"charlm": func(r tokens.TextReader) (Classifier, error) {
+		params, err := charlm.EnvTrainerParams()
+		if err != nil {
+			return nil, err
+		}
+		return charlm.TrainText(r, params)
+	},
+	"ncd": func(r tokens.TextReader) (Classifier, error) {
+		params, err := ncd.EnvTrainerParams()
+		if err != nil {
+			return nil, err
+		}
+		return ncd.TrainText(r, params)
+	},
+	"fasttext": func(r tokens.TextReader) (Classifier, error) {
+		params, err := fasttext.EnvTrainerParams()
+		if err != nil {
+			return nil, err
+		}
+		return fasttext.TrainText(r, params)
+	},
 }
 
 // Decoders maps classifier names to their
@@ -81,14 +271,58 @@ var Decoders = map[string]Decoder{
 	"gaussbayes": func(d []byte) (Classifier, error) {
 		return gaussbayes.DecodeClassifier(d)
 	},
+	"multinomialnb": func(d []byte) (Classifier, error) {
+		return naivebayes.DecodeClassifier(d)
+	},
+	"bernoullinb": func(d []byte) (Classifier, error) {
+		return naivebayes.DecodeClassifier(d)
+	},
+	"complementnb": func(d []byte) (Classifier, error) {
+		return naivebayes.DecodeClassifier(d)
+	},
+	"logreg": func(d []byte) (Classifier, error) {
+		return logreg.DecodeClassifier(d)
+	},
+	"perceptron": func(d []byte) (Classifier, error) {
+		return perceptron.DecodeClassifier(d)
+	},
+	"centroid": func(d []byte) (Classifier, error) {
+		return centroid.DecodeClassifier(d)
+	},
+	"charlm": func(d []byte) (Classifier, error) {
+		return charlm.DecodeClassifier(d)
+	},
+	"ncd": func(d []byte) (Classifier, error) {
+		return ncd.DecodeClassifier(d)
+	},
+	"fasttext": func(d []byte) (Classifier, error) {
+		return fasttext.DecodeClassifier(d)
+	},
 }
 
 // Descriptions maps classifier names to
 // one-line descriptions of the classifier.
 var Descriptions = map[string]string{
-	"idtree":     "decision trees generated with ID3",
-	"neuralnet":  "feedforward neural network",
-	"knn":        "K-nearest neighbors",
-	"svm":        "support vector machines",
-	"gaussbayes": "naive Bayes with Gaussians",
+	"idtree":        "decision trees generated with ID3",
+	"neuralnet":     "feedforward neural network",
+	"knn":           "K-nearest neighbors",
+	"svm":           "support vector machines",
+	"gaussbayes":    "naive Bayes with Gaussians",
+	"multinomialnb": "naive Bayes with a multinomial model",
+	"bernoullinb":   "naive Bayes with a Bernoulli model",
+	"complementnb":  "complement naive Bayes",
+	"logreg":        "multinomial logistic regression",
+	"perceptron":    "averaged perceptron trained online",
+	"centroid":      "nearest centroid (Rocchio) with cosine similarity",
+	"charlm":        "character n-gram language models",
+	"ncd":           "normalized compression distance",
+	"fasttext":      "fastText-style hashed n-gram embeddings",
+}
+
+func trainNaiveBayesCounts(c tokens.SampleCounts, m naivebayes.Model) (Classifier, error) {
+	params, err := naivebayes.EnvTrainerParams(m)
+	if err != nil {
+		return nil, err
+	}
+	return naivebayes.TrainCounts(c, params), nil
 }
@@ -0,0 +1,181 @@
+// Package naivebayes implements naive Bayesian
+// classification with multinomial and Bernoulli
+// models of token occurrences.
+package naivebayes
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// A Model is a way of modeling the tokens of a
+// document given its language.
+type Model int
+
+const (
+	// Multinomial models a document as a sequence
+	// of tokens drawn independently from a
+	// per-language distribution over tokens.
+	Multinomial Model = iota
+
+	// Bernoulli models each token's presence or
+	// absence in a document as an independent coin
+	// flip, ignoring how often it occurs.
+	Bernoulli
+
+	// Complement is a multinomial model which
+	// estimates each language's token distribution
+	// from the samples of every other language, and
+	// picks the language whose complement fits the
+	// document worst.
+	// It works better than Multinomial when some
+	// languages have far more samples than others.
+	Complement
+)
+
+func (m Model) String() string {
+	switch m {
+	case Multinomial:
+		return "multinomial"
+	case Bernoulli:
+		return "bernoulli"
+	case Complement:
+		return "complement"
+	default:
+		return "Model(" + strconv.Itoa(int(m)) + ")"
+	}
+}
+
+// A Classifier scores each language as a bias
+// plus a weighted sum over the tokens in a
+// document, so that tokens which do not appear in
+// the document cost nothing to classify.
+type Classifier struct {
+	Model Model
+
+	Tokens []string
+	Langs  []string
+
+	// Biases stores a bias for each language,
+	// including the language's log prior.
+	Biases []float64
+
+	// Weights stores a weight for each token for
+	// each language, indexed like Langs and Tokens.
+	//
+	// For Multinomial, these are the tokens' log
+	// probabilities.
+	// For Bernoulli, these are the log odds of each
+	// token being present versus absent (the log
+	// probabilities of absence are in Biases).
+	// For Complement, these are the negated and
+	// normalized log probabilities of each token in
+	// the other languages.
+	Weights [][]float64
+
+	// DocumentLength is the number of tokens assumed
+	// to be in each document, used to turn token
+	// frequencies back into counts for the
+	// Multinomial model.
+	DocumentLength float64
+
+	tokenIndicesOnce sync.Once
+	tokenIndices     map[string]int
+}
+
+func DecodeClassifier(d []byte) (*Classifier, error) {
+	var c Classifier
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Biases) != len(c.Langs) || len(c.Weights) != len(c.Langs) {
+		return nil, errors.New("mismatched language count")
+	}
+	for _, w := range c.Weights {
+		if len(w) != len(c.Tokens) {
+			return nil, errors.New("mismatched token count")
+		}
+	}
+	return &c, nil
+}
+
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	scores := c.scores(f)
+	var bestIdx int
+	for i, score := range scores {
+		if score > scores[bestIdx] {
+			bestIdx = i
+		}
+	}
+	return c.Langs[bestIdx]
+}
+
+// LogProbabilities returns the natural logarithm of
+// the posterior probability of each language.
+//
+// For the Complement model, the language scores
+// are normalized in the same way, but they are not
+// calibrated probabilities.
+func (c *Classifier) LogProbabilities(f tokens.Freqs) map[string]float64 {
+	scores := c.scores(f)
+	max := math.Inf(-1)
+	for _, score := range scores {
+		max = math.Max(max, score)
+	}
+	var expSum float64
+	for _, score := range scores {
+		expSum += math.Exp(score - max)
+	}
+	logSum := max + math.Log(expSum)
+
+	res := map[string]float64{}
+	for i, lang := range c.Langs {
+		res[lang] = scores[i] - logSum
+	}
+	return res
+}
+
+func (c *Classifier) Encode() []byte {
+	res, _ := json.Marshal(c)
+	return res
+}
+
+func (c *Classifier) Languages() []string {
+	return c.Langs
+}
+
+func (c *Classifier) scores(f tokens.Freqs) []float64 {
+	c.tokenIndicesOnce.Do(func() {
+		c.tokenIndices = map[string]int{}
+		for i, token := range c.Tokens {
+			c.tokenIndices[token] = i
+		}
+	})
+
+	res := make([]float64, len(c.Langs))
+	copy(res, c.Biases)
+	for token, freq := range f {
+		idx, ok := c.tokenIndices[token]
+		if !ok || freq == 0 {
+			continue
+		}
+		var x float64
+		switch c.Model {
+		case Multinomial:
+			x = freq * c.DocumentLength
+		case Bernoulli:
+			x = 1
+		default:
+			x = freq
+		}
+		for i, weights := range c.Weights {
+			res[i] += x * weights[idx]
+		}
+	}
+	return res
+}
@@ -0,0 +1,69 @@
+package naivebayes
+
+import (
+	"math"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func TestClassify(t *testing.T) {
+	samples := tokens.SampleCounts{
+		"Go": []tokens.Counts{
+			{"func": 3, ":=": 2, "{": 4},
+			{"func": 1, ":=": 3, "{": 2},
+		},
+		"Python": []tokens.Counts{
+			{"def": 2, ":": 4, "self": 3},
+			{"def": 1, ":": 2, "self": 1, "{": 1},
+		},
+	}
+	queries := map[string]tokens.Freqs{
+		"Go":     tokens.Counts{"func": 1, ":=": 1, "{": 2}.Freqs(),
+		"Python": tokens.Counts{"def": 1, "self": 2, ":": 1}.Freqs(),
+	}
+
+	for _, model := range []Model{Multinomial, Bernoulli, Complement} {
+		p := &TrainerParams{Model: model, Smoothing: DefaultSmoothing,
+			DocumentLength: DefaultDocumentLength}
+		for _, c := range []*Classifier{TrainCounts(samples, p),
+			TrainParams(samples.SampleFreqs(), p)} {
+			decoded, err := DecodeClassifier(c.Encode())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for lang, query := range queries {
+				if actual := decoded.Classify(query); actual != lang {
+					t.Errorf("%s: expected %s but got %s", model, lang, actual)
+				}
+			}
+		}
+	}
+}
+
+func TestLogProbabilities(t *testing.T) {
+	samples := tokens.SampleCounts{
+		"A": []tokens.Counts{{"x": 5, "y": 1}, {"x": 4, "y": 2}},
+		"B": []tokens.Counts{{"x": 1, "y": 5}},
+	}
+	c := TrainCounts(samples, &TrainerParams{Model: Multinomial, Smoothing: 1})
+
+	if c.DocumentLength != 6 {
+		t.Errorf("expected document length 6 but got %f", c.DocumentLength)
+	}
+
+	// With Laplace smoothing, P(x|A) = 10/14 and
+	// P(x|B) = 2/8.
+	query := tokens.Freqs{"x": 0.5, "y": 0.5}
+	logA := math.Log(2.0/3) + 3*math.Log(10.0/14) + 3*math.Log(4.0/14)
+	logB := math.Log(1.0/3) + 3*math.Log(2.0/8) + 3*math.Log(6.0/8)
+	expected := logA - math.Log(math.Exp(logA)+math.Exp(logB))
+
+	actual := c.LogProbabilities(query)
+	if math.Abs(actual["A"]-expected) > 1e-8 {
+		t.Errorf("expected log probability %f but got %f", expected, actual["A"])
+	}
+	if sum := math.Exp(actual["A"]) + math.Exp(actual["B"]); math.Abs(sum-1) > 1e-8 {
+		t.Errorf("probabilities sum to %f", sum)
+	}
+}
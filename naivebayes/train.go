@@ -0,0 +1,240 @@
+package naivebayes
+
+import (
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+const (
+	DefaultSmoothing      = 1.0
+	DefaultDocumentLength = 100.0
+)
+
+// These environment variables specify
+// various parameters for the trainer.
+const (
+	// The pseudo-count added to every token's count
+	// for each language.
+	// The default, 1, is Laplace smoothing, while
+	// smaller values give Lidstone smoothing.
+	SmoothingEnvVar = "NAIVEBAYES_SMOOTHING"
+
+	// The number of tokens assumed to be in each
+	// document when training on frequencies rather
+	// than counts.
+	DocumentLengthEnvVar = "NAIVEBAYES_DOCUMENT_LENGTH"
+)
+
+// TrainerParams specifies parameters for the
+// naive Bayes trainer.
+type TrainerParams struct {
+	Model          Model
+	Smoothing      float64
+	DocumentLength float64
+}
+
+// EnvTrainerParams generates TrainerParams
+// for a model by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams(m Model) (*TrainerParams, error) {
+	res := &TrainerParams{
+		Model:          m,
+		Smoothing:      DefaultSmoothing,
+		DocumentLength: DefaultDocumentLength,
+	}
+	if val := os.Getenv(SmoothingEnvVar); val != "" {
+		smoothing, err := strconv.ParseFloat(val, 64)
+		if err != nil || smoothing <= 0 {
+			return nil, errors.New("invalid smoothing: " + val)
+		}
+		res.Smoothing = smoothing
+	}
+	if val := os.Getenv(DocumentLengthEnvVar); val != "" {
+		length, err := strconv.ParseFloat(val, 64)
+		if err != nil || length <= 0 {
+			return nil, errors.New("invalid document length: " + val)
+		}
+		res.DocumentLength = length
+	}
+	return res, nil
+}
+
+// Train trains a Classifier for a model using
+// TrainerParams from EnvTrainerParams.
+func Train(freqs map[string][]tokens.Freqs, m Model) *Classifier {
+	params, err := EnvTrainerParams(m)
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(freqs, params)
+}
+
+// TrainParams trains a Classifier on token
+// frequencies, treating each sample as a document
+// with p.DocumentLength tokens.
+func TrainParams(freqs map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
+	counts := map[string][]tokens.Freqs{}
+	for lang, samples := range freqs {
+		for _, sample := range samples {
+			scaled := tokens.Freqs{}
+			for token, freq := range sample {
+				scaled[token] = freq * p.DocumentLength
+			}
+			counts[lang] = append(counts[lang], scaled)
+		}
+	}
+	return train(counts, p, p.DocumentLength)
+}
+
+// TrainCounts trains a Classifier on token counts.
+// The classifier's DocumentLength is set to the
+// mean number of tokens in the samples, and
+// p.DocumentLength is ignored.
+func TrainCounts(s tokens.SampleCounts, p *TrainerParams) *Classifier {
+	counts := map[string][]tokens.Freqs{}
+	var totalTokens, totalDocs int
+	for lang, samples := range s {
+		for _, sample := range samples {
+			converted := tokens.Freqs{}
+			for token, count := range sample {
+				if token == "" {
+					continue
+				}
+				converted[token] = float64(count)
+				totalTokens += count
+			}
+			counts[lang] = append(counts[lang], converted)
+			totalDocs++
+		}
+	}
+	docLength := p.DocumentLength
+	if totalDocs > 0 && totalTokens > 0 {
+		docLength = float64(totalTokens) / float64(totalDocs)
+	}
+	return train(counts, p, docLength)
+}
+
+// train creates a Classifier from samples which map
+// tokens to (possibly fractional) counts.
+func train(counts map[string][]tokens.Freqs, p *TrainerParams, docLength float64) *Classifier {
+	res := &Classifier{
+		Model:          p.Model,
+		Tokens:         allTokens(counts),
+		DocumentLength: docLength,
+	}
+	for lang := range counts {
+		res.Langs = append(res.Langs, lang)
+	}
+	sort.Strings(res.Langs)
+
+	var totalDocs int
+	for _, samples := range counts {
+		totalDocs += len(samples)
+	}
+
+	tokenIndices := map[string]int{}
+	for i, token := range res.Tokens {
+		tokenIndices[token] = i
+	}
+
+	// tokenTotals[i][j] is the total count of token j
+	// in language i, or the number of samples which
+	// contain it for Bernoulli.
+	tokenTotals := make([][]float64, len(res.Langs))
+	for i, lang := range res.Langs {
+		tokenTotals[i] = make([]float64, len(res.Tokens))
+		for _, sample := range counts[lang] {
+			for token, count := range sample {
+				if count <= 0 {
+					continue
+				}
+				if p.Model == Bernoulli {
+					tokenTotals[i][tokenIndices[token]]++
+				} else {
+					tokenTotals[i][tokenIndices[token]] += count
+				}
+			}
+		}
+	}
+
+	for i, lang := range res.Langs {
+		docCount := float64(len(counts[lang]))
+		logPrior := math.Log(docCount / float64(totalDocs))
+		switch p.Model {
+		case Multinomial:
+			res.Biases = append(res.Biases, logPrior)
+			res.Weights = append(res.Weights, logDistribution(tokenTotals[i], p.Smoothing))
+		case Bernoulli:
+			bias := logPrior
+			weights := make([]float64, len(res.Tokens))
+			for j, present := range tokenTotals[i] {
+				prob := (present + p.Smoothing) / (docCount + 2*p.Smoothing)
+				bias += math.Log(1 - prob)
+				weights[j] = math.Log(prob) - math.Log(1-prob)
+			}
+			res.Biases = append(res.Biases, bias)
+			res.Weights = append(res.Weights, weights)
+		case Complement:
+			complement := make([]float64, len(res.Tokens))
+			for k, totals := range tokenTotals {
+				if k == i {
+					continue
+				}
+				for j, total := range totals {
+					complement[j] += total
+				}
+			}
+			weights := logDistribution(complement, p.Smoothing)
+			var absSum float64
+			for _, w := range weights {
+				absSum += math.Abs(w)
+			}
+			for j := range weights {
+				weights[j] /= -absSum
+			}
+			res.Biases = append(res.Biases, 0)
+			res.Weights = append(res.Weights, weights)
+		default:
+			panic("unknown model: " + p.Model.String())
+		}
+	}
+
+	return res
+}
+
+// logDistribution computes the logs of smoothed
+// probabilities from token totals.
+func logDistribution(totals []float64, smoothing float64) []float64 {
+	sum := smoothing * float64(len(totals))
+	for _, total := range totals {
+		sum += total
+	}
+	res := make([]float64, len(totals))
+	for i, total := range totals {
+		res[i] = math.Log((total + smoothing) / sum)
+	}
+	return res
+}
+
+func allTokens(m map[string][]tokens.Freqs) []string {
+	res := map[string]bool{}
+	for _, samples := range m {
+		for _, sample := range samples {
+			for token := range sample {
+				res[token] = true
+			}
+		}
+	}
+	resSlice := make([]string, 0, len(res))
+	for token := range res {
+		resSlice = append(resSlice, token)
+	}
+	sort.Strings(resSlice)
+	return resSlice
+}
@@ -0,0 +1,206 @@
+// Package ncd classifies text by its normalized
+// compression distance to a reference corpus for
+// each language.
+//
+// It has no learned parameters: a snippet is
+// assigned to the language whose corpus helps the
+// most when compressing it.
+package ncd
+
+import (
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// MaxDictionarySize is the largest dictionary that
+// compress/flate can make use of.
+const MaxDictionarySize = 1 << 15
+
+// A Classifier stores a flate dictionary for each
+// language.
+type Classifier struct {
+	Langs        []string
+	Dictionaries [][]byte
+
+	initOnce sync.Once
+
+	// dictSizes stores the compressed size of each
+	// dictionary.
+	dictSizes []int
+
+	// writers caches a *flate.Writer for each
+	// dictionary, since they are expensive to create.
+	// plainWriters caches writers without one.
+	writers      []*sync.Pool
+	plainWriters *sync.Pool
+}
+
+func DecodeClassifier(d []byte) (*Classifier, error) {
+	var c Classifier
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Dictionaries) != len(c.Langs) {
+		return nil, errors.New("mismatched language count")
+	}
+	for _, dict := range c.Dictionaries {
+		if len(dict) > MaxDictionarySize {
+			return nil, errors.New("dictionary is too large")
+		}
+	}
+	return &c, nil
+}
+
+// Classify classifies a document from its token
+// frequencies, which are turned into text in the
+// same way as by Train.
+// ClassifyText is more accurate when the text is
+// available.
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	return c.ClassifyText(freqsText(f))
+}
+
+// ClassifyText classifies a piece of raw text.
+func (c *Classifier) ClassifyText(text string) string {
+	distances := c.Distances(text)
+	var bestLang string
+	bestDistance := math.Inf(1)
+	for _, lang := range c.Langs {
+		if d := distances[lang]; d < bestDistance {
+			bestDistance = d
+			bestLang = lang
+		}
+	}
+	return bestLang
+}
+
+// Distances computes the normalized compression
+// distance between a piece of text and each
+// language's dictionary:
+//
+//	(C(dx) - min(C(d), C(x))) / max(C(d), C(x))
+//
+// where C is the compressed size and dx is the
+// dictionary followed by the text.
+// Smaller distances mean that the text is more
+// similar to the dictionary.
+func (c *Classifier) Distances(text string) map[string]float64 {
+	c.initOnce.Do(c.init)
+	data := []byte(text)
+	plain := c.plainWriters.Get().(*flate.Writer)
+	textSize := compressedSize(plain, data)
+	c.plainWriters.Put(plain)
+	res := map[string]float64{}
+	for i, lang := range c.Langs {
+		w := c.writers[i].Get().(*flate.Writer)
+		condSize := compressedSize(w, data)
+		c.writers[i].Put(w)
+
+		// Compressing with a preset dictionary gives
+		// C(dx) - C(d).
+		dictSize := c.dictSizes[i]
+		joint := float64(dictSize + condSize)
+		smaller, larger := float64(dictSize), float64(textSize)
+		if smaller > larger {
+			smaller, larger = larger, smaller
+		}
+		res[lang] = (joint - smaller) / larger
+	}
+	return res
+}
+
+func (c *Classifier) Encode() []byte {
+	res, _ := json.Marshal(c)
+	return res
+}
+
+func (c *Classifier) Languages() []string {
+	return c.Langs
+}
+
+func (c *Classifier) init() {
+	c.plainWriters = &sync.Pool{
+		New: func() interface{} {
+			return flateWriter(nil)
+		},
+	}
+	for _, dict := range c.Dictionaries {
+		c.dictSizes = append(c.dictSizes, compressedSize(flateWriter(nil), dict))
+		dict := dict
+		c.writers = append(c.writers, &sync.Pool{
+			New: func() interface{} {
+				return flateWriter(dict)
+			},
+		})
+	}
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+func flateWriter(dict []byte) *flate.Writer {
+	w, err := flate.NewWriterDict(nil, flate.BestCompression, dict)
+	if err != nil {
+		panic(err)
+	}
+	return w
+}
+
+// compressedSize compresses data with w, which
+// keeps its dictionary, and returns the size of
+// the result.
+func compressedSize(w *flate.Writer, data []byte) int {
+	var size countingWriter
+	w.Reset(&size)
+	w.Write(data)
+	w.Close()
+	return int(size)
+}
+
+// freqsText turns token frequencies into text by
+// joining the tokens with spaces, from least to
+// most frequent, so that the most frequent tokens
+// are closest to any text which follows.
+func freqsText(f tokens.Freqs) string {
+	var toks []string
+	for token, freq := range f {
+		if token != "" && freq != 0 {
+			toks = append(toks, token)
+		}
+	}
+	sort.Sort(&tokenSorter{toks, f})
+	return strings.Join(toks, " ")
+}
+
+type tokenSorter struct {
+	tokens []string
+	freqs  tokens.Freqs
+}
+
+func (t *tokenSorter) Len() int {
+	return len(t.tokens)
+}
+
+func (t *tokenSorter) Less(i, j int) bool {
+	fi, fj := t.freqs[t.tokens[i]], t.freqs[t.tokens[j]]
+	if fi == fj {
+		return t.tokens[i] < t.tokens[j]
+	}
+	return fi < fj
+}
+
+func (t *tokenSorter) Swap(i, j int) {
+	t.tokens[i], t.tokens[j] = t.tokens[j], t.tokens[i]
+}
@@ -0,0 +1,106 @@
+package ncd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func TestClassifyText(t *testing.T) {
+	r := &tokens.SliceTextReader{
+		Langs: []string{"Go", "Python", "Go", "Python"},
+		Texts: []string{
+			"func main() {\n\tx := 3\n\tfmt.Println(x)\n}\n",
+			"def main():\n    x = 3\n    print(x)\n",
+			"func (s *Server) Start() error {\n\tif err := s.listen(); err != nil {\n" +
+				"\t\treturn err\n\t}\n\treturn nil\n}\n",
+			"class Server:\n    def start(self):\n        if not self.listen():\n" +
+				"            return None\n",
+		},
+	}
+	trained, err := TrainText(r, &TrainerParams{DictionarySize: 1000, ChunkSize: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := DecodeClassifier(trained.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dict := range c.Dictionaries {
+		if len(dict) > 1000 {
+			t.Errorf("dictionary has %d bytes", len(dict))
+		}
+	}
+
+	queries := map[string]string{
+		"Go":     "if err := s.listen(); err != nil {",
+		"Python": "    def stop(self):\n        return None",
+	}
+	for lang, query := range queries {
+		if actual := c.ClassifyText(query); actual != lang {
+			t.Errorf("expected %s but got %s", lang, actual)
+		}
+	}
+}
+
+func TestTrainTextChunks(t *testing.T) {
+	// Each sample has a header and a footer around a
+	// 16-byte middle which identifies the sample.
+	header := strings.Repeat("// header line\n", 5)
+	footer := strings.Repeat("// footer line\n", 5)
+	r := &tokens.SliceTextReader{}
+	for i := 0; i < 10; i++ {
+		middle := strings.Repeat(fmt.Sprintf("body %02d\n", i), 2)
+		r.Langs = append(r.Langs, "A")
+		r.Texts = append(r.Texts, header+middle+footer)
+	}
+
+	// Only three of the ten middles fit.
+	p := &TrainerParams{DictionarySize: 48, ChunkSize: 16}
+	dictionary := func(seed int64) string {
+		reader := *r
+		p.Seed = seed
+		c, err := TrainText(&reader, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(c.Dictionaries[0])
+	}
+
+	dicts := map[string]bool{}
+	var late bool
+	for seed := int64(0); seed < 10; seed++ {
+		dict := dictionary(seed)
+		if dictionary(seed) != dict {
+			t.Fatalf("seed %d gave different dictionaries", seed)
+		}
+		dicts[dict] = true
+		if len(dict) != 48 {
+			t.Fatalf("expected 48 bytes but got %d", len(dict))
+		}
+		samples := map[string]bool{}
+		for i := 0; i < len(dict); i += 16 {
+			chunk := dict[i : i+16]
+			var sample int
+			if _, err := fmt.Sscanf(chunk, "body %d\n", &sample); err != nil ||
+				chunk != strings.Repeat(fmt.Sprintf("body %02d\n", sample), 2) {
+				t.Fatalf("chunk is not the middle of a sample: %q", chunk)
+			}
+			if samples[chunk] {
+				t.Fatalf("duplicate chunk: %q", chunk)
+			}
+			samples[chunk] = true
+			if sample >= 3 {
+				late = true
+			}
+		}
+	}
+	if len(dicts) < 2 {
+		t.Error("every seed picked the same samples")
+	}
+	if !late {
+		t.Error("samples after the first three were never picked")
+	}
+}
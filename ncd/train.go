@@ -0,0 +1,189 @@
+package ncd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+const (
+	DefaultDictionarySize = MaxDictionarySize
+	DefaultChunkSize      = 512
+)
+
+// These environment variables specify
+// various parameters for the trainer.
+const (
+	// The number of bytes in each language's
+	// dictionary, up to 32768.
+	DictionarySizeEnvVar = "NCD_DICTIONARY_SIZE"
+
+	// The number of bytes taken from each sample
+	// file when building dictionaries from text.
+	ChunkSizeEnvVar = "NCD_CHUNK_SIZE"
+
+	// The seed for choosing which samples go in a
+	// dictionary when building dictionaries from text.
+	// By default, the current time is used.
+	SeedEnvVar = "NCD_SEED"
+)
+
+// TrainerParams specifies parameters for building
+// dictionaries.
+type TrainerParams struct {
+	DictionarySize int
+	ChunkSize      int
+	Seed           int64
+}
+
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := &TrainerParams{
+		DictionarySize: DefaultDictionarySize,
+		ChunkSize:      DefaultChunkSize,
+		Seed:           time.Now().UnixNano(),
+	}
+	if val := os.Getenv(DictionarySizeEnvVar); val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil || size < 1 || size > MaxDictionarySize {
+			return nil, errors.New("invalid dictionary size: " + val)
+		}
+		res.DictionarySize = size
+	}
+	if val := os.Getenv(ChunkSizeEnvVar); val != "" {
+		size, err := strconv.Atoi(val)
+		if err != nil || size < 1 {
+			return nil, errors.New("invalid chunk size: " + val)
+		}
+		res.ChunkSize = size
+	}
+	if val := os.Getenv(SeedEnvVar); val != "" {
+		seed, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, errors.New("invalid seed: " + val)
+		}
+		res.Seed = seed
+	}
+	return res, nil
+}
+
+// Train builds a Classifier using TrainerParams
+// from EnvTrainerParams.
+func Train(data map[string][]tokens.Freqs) *Classifier {
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(data, params)
+}
+
+// TrainParams builds a Classifier from token
+// frequencies.
+// Each dictionary lists a language's most frequent
+// tokens, separated by spaces.
+//
+// Use TrainText when the raw text is available.
+func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
+	res := &Classifier{}
+	for lang := range data {
+		res.Langs = append(res.Langs, lang)
+	}
+	sort.Strings(res.Langs)
+	for _, lang := range res.Langs {
+		total := tokens.Freqs{}
+		for _, sample := range data[lang] {
+			for token, freq := range sample {
+				total[token] += freq
+			}
+		}
+		dict := []byte(freqsText(total))
+		if len(dict) > p.DictionarySize {
+			dict = dict[len(dict)-p.DictionarySize:]
+			if idx := bytes.IndexByte(dict, ' '); idx >= 0 {
+				dict = dict[idx+1:]
+			}
+		}
+		res.Dictionaries = append(res.Dictionaries, dict)
+	}
+	return res
+}
+
+// TrainText builds a Classifier from the text of
+// every document from a tokens.TextReader.
+//
+// Each dictionary is made of p.ChunkSize byte
+// chunks from the middle of a language's samples,
+// chosen uniformly at random when there are more
+// samples than fit in a dictionary.
+// The choice only depends on p.Seed and the order
+// of the samples.
+func TrainText(r tokens.TextReader, p *TrainerParams) (*Classifier, error) {
+	gen := rand.New(rand.NewSource(p.Seed))
+	maxChunks := (p.DictionarySize + p.ChunkSize - 1) / p.ChunkSize
+	chunks := map[string][][]byte{}
+	seen := map[string]int{}
+	for {
+		lang, text, err := r.ReadText()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		chunk := middleChunk(text, p.ChunkSize)
+		if len(chunk) == 0 {
+			continue
+		}
+
+		// Reservoir sampling keeps a uniform sample of
+		// chunks without storing all of them.
+		seen[lang]++
+		if len(chunks[lang]) < maxChunks {
+			chunks[lang] = append(chunks[lang], chunk)
+		} else if idx := gen.Intn(seen[lang]); idx < maxChunks {
+			chunks[lang][idx] = chunk
+		}
+	}
+
+	res := &Classifier{}
+	for lang := range chunks {
+		res.Langs = append(res.Langs, lang)
+	}
+	sort.Strings(res.Langs)
+	for _, lang := range res.Langs {
+		dict := bytes.Join(chunks[lang], nil)
+		if len(dict) > p.DictionarySize {
+			dict = dict[len(dict)-p.DictionarySize:]
+		}
+		res.Dictionaries = append(res.Dictionaries, dict)
+	}
+	return res, nil
+}
+
+// middleChunk returns up to size bytes from the
+// middle of a document, starting at a line.
+// This skips the license headers and imports at the
+// start of many source files.
+func middleChunk(text string, size int) []byte {
+	start := 0
+	if len(text) > size {
+		start = (len(text) - size) / 2
+		for start > 0 && text[start-1] != '\n' {
+			start--
+		}
+	}
+	end := start + size
+	if end > len(text) {
+		end = len(text)
+	}
+	return []byte(text[start:end])
+}
@@ -0,0 +1,113 @@
+package neuralnet
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+)
+
+// A Checkpoint stores the state of a training run,
+// so that training can be resumed with Resume if
+// it is interrupted.
+type Checkpoint struct {
+	Params TrainerParams
+
+	// StepSizeIndex is the index in Params.StepSizes
+	// of the step size being trained.
+	StepSizeIndex int
+
+	// Best is the best network trained with the
+	// previous step sizes, if there is one.
+	Best           *Network `json:",omitempty"`
+	BestCrossScore float64
+	BestTrainScore float64
+
+	// Network is the network being trained.
+	// If it is nil, training with the current step
+	// size has not started yet.
+	Network *Network `json:",omitempty"`
+	Epoch   int
+
+	// These fields store the trainer's progress
+	// towards choosing the number of epochs.
+	NextCheck      int
+	LastNetwork    *Network `json:",omitempty"`
+	LastCrossScore float64
+	LastTrainScore float64
+
+	// These fields store the optimizer's state.
+	OptimizerSteps  int
+	OptimizerFirst  [][][]float64 `json:",omitempty"`
+	OptimizerSecond [][][]float64 `json:",omitempty"`
+}
+
+// ReadCheckpoint reads a Checkpoint which was
+// written by Write.
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var res Checkpoint
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	for _, n := range []*Network{res.Best, res.Network, res.LastNetwork} {
+		if n != nil && len(n.Layers) == 0 {
+			return nil, errors.New("checkpoint contains a network without layers")
+		}
+	}
+	return &res, nil
+}
+
+// Write saves the checkpoint to a file.
+// The file is replaced atomically, so a previous
+// checkpoint at the same path survives if writing
+// is interrupted.
+func (c *Checkpoint) Write(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// checkpoint creates a Checkpoint for the trainer.
+func (t *Trainer) checkpoint() *Checkpoint {
+	return &Checkpoint{
+		Params:          *t.p,
+		Network:         t.n,
+		Epoch:           t.epoch,
+		NextCheck:       t.nextCheck,
+		LastNetwork:     t.lastNet,
+		LastCrossScore:  t.lastCross,
+		LastTrainScore:  t.lastTrain,
+		OptimizerSteps:  t.opt.steps,
+		OptimizerFirst:  t.opt.first,
+		OptimizerSecond: t.opt.second,
+	}
+}
+
+// trainer recreates the Trainer which was used to
+// create the checkpoint.
+func (c *Checkpoint) trainer(d *DataSet, p *TrainerParams) *Trainer {
+	t := newTrainer(d, p, p.StepSizes[c.StepSizeIndex], c.Network)
+	t.epoch = c.Epoch
+	t.nextCheck = c.NextCheck
+	t.lastNet = c.LastNetwork
+	t.lastCross = c.LastCrossScore
+	t.lastTrain = c.LastTrainScore
+	t.opt.steps = c.OptimizerSteps
+	if c.OptimizerFirst != nil {
+		t.opt.first = c.OptimizerFirst
+	}
+	if c.OptimizerSecond != nil {
+		t.opt.second = c.OptimizerSecond
+	}
+	return t
+}
@@ -0,0 +1,51 @@
+package neuralnet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResumeCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+
+	p := testParams()
+	ds := NewDataSetRand(testSamples(), rand.New(rand.NewSource(p.Seed)))
+
+	uninterrupted := NewTrainer(ds, p, p.StepSizes[0])
+	uninterrupted.Train(p.MaxIterations)
+	expected := uninterrupted.Network().Encode()
+
+	// Save a checkpoint in the middle of a run, as if
+	// the run were interrupted afterwards.
+	interrupted := NewTrainer(ds, p, p.StepSizes[0])
+	interrupted.onCheckpoint = func() {
+		if interrupted.epoch == 5 {
+			if err := interrupted.checkpoint().Write(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	interrupted.Train(p.MaxIterations)
+
+	checkpoint, err := ReadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.Epoch != 5 {
+		t.Fatalf("expected epoch 5 but got %d", checkpoint.Epoch)
+	}
+	resumed := checkpoint.trainer(ds, &checkpoint.Params)
+	resumed.Train(p.MaxIterations)
+	if !bytes.Equal(resumed.Network().Encode(), expected) {
+		t.Error("resumed network differs from uninterrupted network")
+	}
+}
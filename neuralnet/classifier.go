@@ -4,93 +4,121 @@ import (
 	"encoding/json"
 	"math"
 
-	"github.com/unixpickle/num-analysis/kahan"
 	"github.com/unixpickle/whichlang/tokens"
 )
 
 // A Network is a feedforward neural network with
-// a single hidden layer.
+// any number of hidden layers.
 type Network struct {
 	Tokens []string
 	Langs  []string
 
-	// In the following weights, the last weight for
-	// each neuron corresponds to a constant shift,
-	// and is not multiplied by an input's value.
-	HiddenWeights [][]float64
-	OutputWeights [][]float64
+	// Layers stores the hidden layers, followed by
+	// the output layer, which has one neuron per
+	// language.
+	Layers []*Layer
 
 	// Information used to centralize the training
 	// weights around 0 and get them to have a
 	// standard deviation of 1.
 	InputShift float64
 	InputScale float64
+
+	// FeatureShifts and FeatureScales, if set, are
+	// used in place of InputShift and InputScale to
+	// standardize each token's frequency separately.
+	// They are indexed in the same order as Tokens.
+	FeatureShifts []float64 `json:",omitempty"`
+	FeatureScales []float64 `json:",omitempty"`
+}
+
+// legacyNetwork is the format of networks which
+// had exactly one sigmoid hidden layer and sigmoid
+// outputs.
+type legacyNetwork struct {
+	HiddenWeights [][]float64
+	OutputWeights [][]float64
 }
 
+// DecodeNetwork decodes a Network, including
+// networks encoded before multiple hidden layers
+// were supported.
 func DecodeNetwork(data []byte) (*Network, error) {
 	var n Network
 	if err := json.Unmarshal(data, &n); err != nil {
 		return nil, err
 	}
+	if len(n.Layers) == 0 {
+		var legacy legacyNetwork
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return nil, err
+		}
+		n.Layers = []*Layer{
+			{Weights: legacy.HiddenWeights, Activation: SigmoidActivation},
+			{Weights: legacy.OutputWeights, Activation: SigmoidActivation},
+		}
+	}
 	return &n, nil
 }
 
 func (n *Network) Copy() *Network {
 	res := &Network{
-		Tokens:        make([]string, len(n.Tokens)),
-		Langs:         make([]string, len(n.Langs)),
-		HiddenWeights: make([][]float64, len(n.HiddenWeights)),
-		OutputWeights: make([][]float64, len(n.OutputWeights)),
-		InputShift:    n.InputShift,
-		InputScale:    n.InputScale,
+		Tokens:     make([]string, len(n.Tokens)),
+		Langs:      make([]string, len(n.Langs)),
+		Layers:     make([]*Layer, len(n.Layers)),
+		InputShift: n.InputShift,
+		InputScale: n.InputScale,
 	}
 	copy(res.Tokens, n.Tokens)
 	copy(res.Langs, n.Langs)
-	for i, w := range n.HiddenWeights {
-		res.HiddenWeights[i] = make([]float64, len(w))
-		copy(res.HiddenWeights[i], w)
+	if n.FeatureShifts != nil {
+		res.FeatureShifts = append([]float64{}, n.FeatureShifts...)
+		res.FeatureScales = append([]float64{}, n.FeatureScales...)
 	}
-	for i, w := range n.OutputWeights {
-		res.OutputWeights[i] = make([]float64, len(w))
-		copy(res.OutputWeights[i], w)
+	for i, l := range n.Layers {
+		res.Layers[i] = l.Copy()
 	}
 	return res
 }
 
 func (n *Network) Classify(freqs tokens.Freqs) string {
-	inputs := n.shiftedInput(freqs)
-
-	outputSums := make([]*kahan.Summer64, len(n.OutputWeights))
-	for i := range outputSums {
-		outputSums[i] = kahan.NewSummer64()
-		outputSums[i].Add(n.outputBias(i))
-	}
+	outputs := n.outputs(n.shiftedInput(freqs))
 
-	for hiddenIndex, hiddenWeights := range n.HiddenWeights {
-		hiddenSum := kahan.NewSummer64()
-		for j, input := range inputs {
-			hiddenSum.Add(input * hiddenWeights[j])
-		}
-		hiddenSum.Add(n.hiddenBias(hiddenIndex))
-
-		hiddenOut := sigmoid(hiddenSum.Sum())
-		for j, outSum := range outputSums {
-			weight := n.OutputWeights[j][hiddenIndex]
-			outSum.Add(weight * hiddenOut)
-		}
-	}
-
-	maxSum := outputSums[0].Sum()
+	maxSum := outputs[0]
 	maxIdx := 0
-	for i, x := range outputSums {
-		if x.Sum() > maxSum {
-			maxSum = x.Sum()
+	for i, x := range outputs {
+		if x > maxSum {
+			maxSum = x
 			maxIdx = i
 		}
 	}
 	return n.Langs[maxIdx]
 }
 
+// Probabilities returns the probability of each
+// language for the given frequencies.
+//
+// For networks with a softmax output layer, these
+// are the network's outputs.
+// For networks with other output activations, the
+// outputs are scaled to sum to 1, in which case
+// they should be regarded as scores rather than
+// calibrated probabilities.
+func (n *Network) Probabilities(freqs tokens.Freqs) map[string]float64 {
+	outputs := n.outputs(n.shiftedInput(freqs))
+	var sum float64
+	for _, x := range outputs {
+		sum += x
+	}
+	res := map[string]float64{}
+	for i, lang := range n.Langs {
+		if sum != 0 {
+			res[lang] = outputs[i] / sum
+		}
+	}
+	return res
+}
+
 func (n *Network) Encode() []byte {
 	enc, _ := json.Marshal(n)
 	return enc
@@ -100,17 +128,20 @@ func (n *Network) Languages() []string {
 	return n.Langs
 }
 
-func (n *Network) outputBias(outputIdx int) float64 {
-	return n.OutputWeights[outputIdx][len(n.HiddenWeights)]
-}
-
-func (n *Network) hiddenBias(hiddenIdx int) float64 {
-	return n.HiddenWeights[hiddenIdx][len(n.Tokens)]
+// outputs runs the network on a shifted input and
+// returns the output layer's outputs.
+func (n *Network) outputs(inputs []float64) []float64 {
+	for _, layer := range n.Layers {
+		out := make([]float64, len(layer.Weights))
+		layer.Apply(inputs, out)
+		inputs = out
+	}
+	return inputs
 }
 
 func (n *Network) containsNaN() bool {
-	for _, wss := range [][][]float64{n.HiddenWeights, n.OutputWeights} {
-		for _, ws := range wss {
+	for _, layer := range n.Layers {
+		for _, ws := range layer.Weights {
 			for _, w := range ws {
 				if math.IsNaN(w) {
 					return true
@@ -124,11 +155,11 @@ func (n *Network) containsNaN() bool {
 func (n *Network) shiftedInput(f tokens.Freqs) []float64 {
 	res := make([]float64, len(n.Tokens))
 	for i, word := range n.Tokens {
-		res[i] = (f[word] + n.InputShift) * n.InputScale
+		if n.FeatureShifts != nil {
+			res[i] = (f[word] + n.FeatureShifts[i]) * n.FeatureScales[i]
+		} else {
+			res[i] = (f[word] + n.InputShift) * n.InputScale
+		}
 	}
 	return res
 }
-
-func sigmoid(x float64) float64 {
-	return 1.0 / (1.0 + math.Exp(-x))
-}
@@ -11,6 +11,24 @@ import (
 
 const ValidationFraction = 0.3
 
+// A Normalization specifies how a Network
+// standardizes its inputs.
+type Normalization int
+
+const (
+	// FeatureNormalization gives each token's
+	// frequency a mean of 0 and a standard deviation
+	// of 1 in the training samples.
+	// Without it, rare tokens get tiny inputs next to
+	// common tokens like "(" or ";".
+	FeatureNormalization Normalization = iota
+
+	// GlobalNormalization shifts and scales every
+	// token's frequency by the same amount, using the
+	// mean and standard deviation of all frequencies.
+	GlobalNormalization
+)
+
 // A DataSet is a set of data split into training
 // samples and validation samples.
 type DataSet struct {
@@ -22,19 +40,38 @@ type DataSet struct {
 	// training samples' frequency values.
 	MeanFrequency   float64
 	FrequencyStddev float64
+
+	// These are the statistical properties of each
+	// token's frequencies in the training samples,
+	// indexed in the same order as Tokens().
+	FeatureMeans   []float64
+	FeatureStddevs []float64
 }
 
 // NewDataSet creates a DataSet by randomly
 // partitioning some data samples into
 // validation and training samples.
 func NewDataSet(samples map[string][]tokens.Freqs) *DataSet {
+	return NewDataSetRand(samples, rand.New(rand.NewSource(rand.Int63())))
+}
+
+// NewDataSetRand is like NewDataSet, but it uses
+// r to partition the samples, so that the result
+// is reproducible.
+func NewDataSetRand(samples map[string][]tokens.Freqs, r *rand.Rand) *DataSet {
 	res := &DataSet{
 		ValidationSamples: map[string][]tokens.Freqs{},
 		TrainingSamples:   map[string][]tokens.Freqs{},
 	}
-	for lang, langSamples := range samples {
+	langs := make([]string, 0, len(samples))
+	for lang := range samples {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		langSamples := samples[lang]
 		shuffled := make([]tokens.Freqs, len(langSamples))
-		perm := rand.Perm(len(shuffled))
+		perm := r.Perm(len(shuffled))
 		for i, x := range perm {
 			shuffled[i] = langSamples[x]
 		}
@@ -46,6 +83,7 @@ func NewDataSet(samples map[string][]tokens.Freqs) *DataSet {
 
 	res.computeStatistics()
 	res.computeNormalSamples()
+	res.computeFeatureStatistics()
 
 	return res
 }
@@ -96,13 +134,15 @@ func (c *DataSet) Langs() []string {
 func (c *DataSet) computeStatistics() {
 	tokens := c.Tokens()
 
+	// Samples are visited in a fixed order so that
+	// the statistics are reproducible bit-for-bit.
 	freqSum := kahan.NewSummer64()
 	freqCount := 0
-	for _, langSamples := range c.TrainingSamples {
-		for _, sample := range langSamples {
+	for _, lang := range c.Langs() {
+		for _, sample := range c.TrainingSamples[lang] {
 			freqCount += len(tokens)
-			for _, freq := range sample {
-				freqSum.Add(freq)
+			for _, token := range tokens {
+				freqSum.Add(sample[token])
 			}
 		}
 	}
@@ -110,8 +150,8 @@ func (c *DataSet) computeStatistics() {
 	c.MeanFrequency = freqSum.Sum() / float64(freqCount)
 
 	variationSum := kahan.NewSummer64()
-	for _, langSamples := range c.TrainingSamples {
-		for _, sample := range langSamples {
+	for _, lang := range c.Langs() {
+		for _, sample := range c.TrainingSamples[lang] {
 			for _, token := range tokens {
 				freq := sample[token]
 				variationSum.Add(math.Pow(freq-c.MeanFrequency, 2))
@@ -139,6 +179,40 @@ func (c *DataSet) computeNormalSamples() {
 	}
 }
 
+func (c *DataSet) computeFeatureStatistics() {
+	tokens := c.Tokens()
+	langs := c.Langs()
+	c.FeatureMeans = make([]float64, len(tokens))
+	c.FeatureStddevs = make([]float64, len(tokens))
+
+	var count int
+	for _, langSamples := range c.TrainingSamples {
+		count += len(langSamples)
+	}
+	if count == 0 {
+		return
+	}
+
+	for i, token := range tokens {
+		sum := kahan.NewSummer64()
+		for _, lang := range langs {
+			for _, sample := range c.TrainingSamples[lang] {
+				sum.Add(sample[token])
+			}
+		}
+		mean := sum.Sum() / float64(count)
+
+		variationSum := kahan.NewSummer64()
+		for _, lang := range langs {
+			for _, sample := range c.TrainingSamples[lang] {
+				variationSum.Add(math.Pow(sample[token]-mean, 2))
+			}
+		}
+		c.FeatureMeans[i] = mean
+		c.FeatureStddevs[i] = math.Sqrt(variationSum.Sum() / float64(count))
+	}
+}
+
 func scoreNetwork(n *Network, samples map[string][]tokens.Freqs) float64 {
 	var totalRight int
 	var total int
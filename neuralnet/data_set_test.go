@@ -0,0 +1,27 @@
+package neuralnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNormalTrainingSamples(t *testing.T) {
+	ds := NewDataSetRand(testSamples(), rand.New(rand.NewSource(1)))
+	tokens := ds.Tokens()
+	for lang, samples := range ds.TrainingSamples {
+		normal := ds.NormalTrainingSamples[lang]
+		if len(normal) != len(samples) {
+			t.Fatalf("%s: expected %d samples but got %d", lang, len(samples), len(normal))
+		}
+		for i, sample := range samples {
+			for j, token := range tokens {
+				expected := (sample[token] - ds.MeanFrequency) / ds.FrequencyStddev
+				if math.Abs(normal[i][j]-expected) > 1e-12 {
+					t.Errorf("%s: sample %d: %s: expected %f but got %f", lang, i,
+						token, expected, normal[i][j])
+				}
+			}
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package neuralnet
 
 import (
+	"errors"
 	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const DefaultMaxIterations = 6400
@@ -13,6 +16,18 @@ const DefaultMaxIterations = 6400
 // layer, by default.
 const DefaultHiddenLayerScale = 2.0
 
+const (
+	DefaultBatchSize          = 32
+	DefaultMomentum           = 0.9
+	DefaultDecayEpochs        = 100
+	DefaultCheckpointInterval = 10
+)
+
+// defaultAdamStepSizes are the step sizes tried
+// when using the Adam optimizer, which is far less
+// sensitive to its step size than SGD.
+var defaultAdamStepSizes = []float64{1e-4, 1e-3, 1e-2}
+
 // VerboseEnvVar is an environment variable
 // which can be set to "1" to make the
 // neuralnet print out status reports.
@@ -36,52 +51,297 @@ var MaxItersEnvVar = "NEURALNET_MAX_ITERS"
 
 // HiddenSizeEnvVar is an environment variable
 // specifying the number of hidden neurons.
+// It may be a comma-separated list, such as
+// "100,50", to use multiple hidden layers.
 var HiddenSizeEnvVar = "NEURALNET_HIDDEN_SIZE"
 
-func verboseFlag() bool {
-	return os.Getenv(VerboseEnvVar) == "1"
-}
+// ActivationEnvVar is an environment variable
+// specifying the activation for hidden layers,
+// which may be "sigmoid", "tanh", or "relu".
+// The default is "sigmoid".
+var ActivationEnvVar = "NEURALNET_ACTIVATION"
+
+// OptimizerEnvVar is an environment variable
+// which may be set to "sgd", "momentum", or
+// "adam" to choose the optimizer.
+// The default is "adam".
+var OptimizerEnvVar = "NEURALNET_OPTIMIZER"
+
+// BatchSizeEnvVar is an environment variable
+// specifying the number of samples in each
+// mini-batch.
+var BatchSizeEnvVar = "NEURALNET_BATCH_SIZE"
+
+// MomentumEnvVar is an environment variable
+// specifying the momentum coefficient for the
+// "momentum" optimizer.
+var MomentumEnvVar = "NEURALNET_MOMENTUM"
+
+// WeightDecayEnvVar is an environment variable
+// specifying the coefficient for L2 weight decay.
+// The default is 0.
+var WeightDecayEnvVar = "NEURALNET_WEIGHT_DECAY"
+
+// DropoutEnvVar is an environment variable
+// specifying the probability (from 0-1) that a
+// hidden neuron is dropped during training.
+// The default is 0.
+var DropoutEnvVar = "NEURALNET_DROPOUT"
+
+// ScheduleEnvVar is an environment variable
+// which may be set to "constant", "step", or
+// "cosine" to choose the learning rate schedule.
+// The default is "constant".
+var ScheduleEnvVar = "NEURALNET_SCHEDULE"
+
+// DecayEpochsEnvVar is an environment variable
+// specifying how many epochs pass between each
+// halving of the learning rate for the "step"
+// schedule.
+var DecayEpochsEnvVar = "NEURALNET_DECAY_EPOCHS"
+
+// NormalizationEnvVar is an environment variable
+// which may be set to "feature" to standardize
+// each token's frequency separately, or "global"
+// to standardize all frequencies together.
+// The default is "feature".
+var NormalizationEnvVar = "NEURALNET_NORMALIZATION"
+
+// SeedEnvVar is an environment variable
+// specifying the seed for the random number
+// generator used by the trainer.
+// By default, the seed is based on the time.
+var SeedEnvVar = "NEURALNET_SEED"
+
+// CheckpointEnvVar is an environment variable
+// specifying a file to which training checkpoints
+// are saved.
+// By default, no checkpoints are saved.
+var CheckpointEnvVar = "NEURALNET_CHECKPOINT"
+
+// CheckpointEpochsEnvVar is an environment
+// variable specifying the number of epochs between
+// checkpoints.
+var CheckpointEpochsEnvVar = "NEURALNET_CHECKPOINT_EPOCHS"
+
+// ScoresEnvVar is an environment variable
+// specifying a CSV file to which the cross and
+// training scores are appended after every epoch.
+var ScoresEnvVar = "NEURALNET_SCORES"
+
+// TrainerParams specifies parameters for the
+// neural network trainer.
+type TrainerParams struct {
+	Verbose      bool
+	VerboseSteps bool
 
-func verboseStepsFlag() bool {
-	return os.Getenv(VerboseStepsEnvVar) == "1"
+	// StepSizes lists the step sizes to try.
+	// The network with the best cross-validation
+	// score is kept.
+	StepSizes []float64
+
+	MaxIterations int
+
+	// HiddenSizes lists the number of neurons in
+	// each hidden layer.
+	// If it is nil, a single hidden layer is used,
+	// scaled by DefaultHiddenLayerScale.
+	HiddenSizes []int
+	Activation  Activation
+
+	Optimizer Optimizer
+	Momentum  float64
+
+	// BatchSize is the number of samples in each
+	// mini-batch, or 0 for DefaultBatchSize.
+	BatchSize int
+
+	WeightDecay float64
+	Dropout     float64
+
+	Schedule Schedule
+
+	// DecayEpochs is used by StepSchedule, and is
+	// DefaultDecayEpochs if it is 0.
+	DecayEpochs int
+
+	Normalization Normalization
+
+	// Seed seeds the random number generator used
+	// to split the data, initialize the weights,
+	// shuffle samples, and drop neurons.
+	// Training runs with the same Seed and
+	// parameters produce identical networks,
+	// regardless of the number of CPUs.
+	Seed int64
+
+	// CheckpointPath, if set, is the file where a
+	// Checkpoint is saved every CheckpointInterval
+	// epochs, and after each step size finishes.
+	CheckpointPath     string
+	CheckpointInterval int
+
+	// ScoresPath, if set, is the CSV file where the
+	// scores are recorded after each epoch.
+	ScoresPath string
 }
 
-func stepSizes() []float64 {
-	if stepSize := os.Getenv(StepSizeEnvVar); stepSize == "" {
-		var res []float64
-		for power := -20; power < 10; power++ {
-			res = append(res, math.Pow(2, float64(power)))
-		}
-		return res
-	} else {
-		val, err := strconv.ParseFloat(stepSize, 64)
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+// When a variable is missing, a default value
+// or set of values will be used.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := TrainerParams{
+		Verbose:       os.Getenv(VerboseEnvVar) == "1",
+		VerboseSteps:  os.Getenv(VerboseStepsEnvVar) == "1",
+		MaxIterations: DefaultMaxIterations,
+		Activation:    SigmoidActivation,
+		Optimizer:     AdamOptimizer,
+		BatchSize:     DefaultBatchSize,
+		Momentum:      DefaultMomentum,
+		Schedule:      ConstantSchedule,
+		DecayEpochs:   DefaultDecayEpochs,
+		Normalization: FeatureNormalization,
+		Seed:          time.Now().UnixNano(),
+
+		CheckpointPath:     os.Getenv(CheckpointEnvVar),
+		CheckpointInterval: DefaultCheckpointInterval,
+		ScoresPath:         os.Getenv(ScoresEnvVar),
+	}
+	var err error
+
+	if val := os.Getenv(SeedEnvVar); val != "" {
+		res.Seed, err = strconv.ParseInt(val, 10, 64)
 		if err != nil {
-			panic(err)
+			return nil, errors.New("invalid seed: " + val)
 		}
-		return []float64{val}
 	}
-}
 
-func maxIterations() int {
-	if max := os.Getenv(MaxItersEnvVar); max == "" {
-		return DefaultMaxIterations
-	} else {
-		val, err := strconv.Atoi(max)
-		if err != nil {
-			panic(err)
+	switch val := os.Getenv(OptimizerEnvVar); val {
+	case "", "adam":
+	case "sgd":
+		res.Optimizer = SGDOptimizer
+	case "momentum":
+		res.Optimizer = MomentumOptimizer
+	default:
+		return nil, errors.New("unknown optimizer: " + val)
+	}
+
+	switch val := os.Getenv(ScheduleEnvVar); val {
+	case "", "constant":
+	case "step":
+		res.Schedule = StepSchedule
+	case "cosine":
+		res.Schedule = CosineSchedule
+	default:
+		return nil, errors.New("unknown schedule: " + val)
+	}
+
+	switch val := os.Getenv(NormalizationEnvVar); val {
+	case "", "feature":
+	case "global":
+		res.Normalization = GlobalNormalization
+	default:
+		return nil, errors.New("unknown normalization: " + val)
+	}
+
+	if val := os.Getenv(ActivationEnvVar); val != "" {
+		res.Activation, err = ParseActivation(val)
+		if err != nil || res.Activation == SoftmaxActivation {
+			return nil, errors.New("invalid hidden activation: " + val)
 		}
-		return val
 	}
-}
 
-func hiddenSize(outputCount int) int {
-	if size := os.Getenv(HiddenSizeEnvVar); size == "" {
-		return int(float64(outputCount)*DefaultHiddenLayerScale + 0.5)
-	} else {
-		val, err := strconv.Atoi(size)
+	if val := os.Getenv(StepSizeEnvVar); val != "" {
+		stepSize, err := strconv.ParseFloat(val, 64)
 		if err != nil {
-			panic(err)
+			return nil, errors.New("invalid step size: " + val)
+		}
+		res.StepSizes = []float64{stepSize}
+	} else if res.Optimizer == AdamOptimizer {
+		res.StepSizes = defaultAdamStepSizes
+	} else {
+		for power := -20; power < 10; power++ {
+			res.StepSizes = append(res.StepSizes, math.Pow(2, float64(power)))
+		}
+	}
+
+	if val := os.Getenv(HiddenSizeEnvVar); val != "" {
+		for _, part := range strings.Split(val, ",") {
+			size, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || size < 1 {
+				return nil, errors.New("invalid hidden size: " + val)
+			}
+			res.HiddenSizes = append(res.HiddenSizes, size)
 		}
-		return val
 	}
+
+	intVars := []struct {
+		name  string
+		value *int
+	}{
+		{MaxItersEnvVar, &res.MaxIterations},
+		{BatchSizeEnvVar, &res.BatchSize},
+		{DecayEpochsEnvVar, &res.DecayEpochs},
+		{CheckpointEpochsEnvVar, &res.CheckpointInterval},
+	}
+	for _, v := range intVars {
+		if val := os.Getenv(v.name); val != "" {
+			num, err := strconv.Atoi(val)
+			if err != nil || num < 1 {
+				return nil, errors.New("invalid " + v.name + ": " + val)
+			}
+			*v.value = num
+		}
+	}
+
+	floatVars := []struct {
+		name  string
+		value *float64
+		max   float64
+	}{
+		{MomentumEnvVar, &res.Momentum, 1},
+		{WeightDecayEnvVar, &res.WeightDecay, math.Inf(1)},
+		{DropoutEnvVar, &res.Dropout, 1},
+	}
+	for _, v := range floatVars {
+		if val := os.Getenv(v.name); val != "" {
+			num, err := strconv.ParseFloat(val, 64)
+			if err != nil || num < 0 || num >= v.max {
+				return nil, errors.New("invalid " + v.name + ": " + val)
+			}
+			*v.value = num
+		}
+	}
+
+	return &res, nil
+}
+
+// hiddenSizes returns the sizes of the hidden
+// layers for a network with the given number of
+// outputs.
+func (p *TrainerParams) hiddenSizes(outputCount int) []int {
+	if p.HiddenSizes != nil {
+		return p.HiddenSizes
+	}
+	return []int{int(float64(outputCount)*DefaultHiddenLayerScale + 0.5)}
+}
+
+// withDefaults returns p, or a copy of p in which
+// the fields that must be positive but are not,
+// as when p is built in code, have their defaults.
+func (p *TrainerParams) withDefaults() *TrainerParams {
+	if p.BatchSize > 0 && p.DecayEpochs > 0 {
+		return p
+	}
+	res := *p
+	if res.BatchSize < 1 {
+		res.BatchSize = DefaultBatchSize
+	}
+	if res.DecayEpochs < 1 {
+		res.DecayEpochs = DefaultDecayEpochs
+	}
+	return &res
 }
@@ -1,10 +1,19 @@
 package neuralnet
 
-import "github.com/unixpickle/num-analysis/kahan"
+import "math/rand"
 
 // A gradientCalc can compute gradients of the
-// error function 0.5*||Actual - Expected||^2
-// for a neural network on a given input.
+// error function for a neural network on a given
+// input.
+//
+// For softmax output layers, the error function is
+// the cross-entropy -log(p_expected).
+// For other output layers, the error function is
+// 0.5*||Actual - Expected||^2.
+//
+// If dropout is enabled, each call to Compute
+// drops a random subset of the hidden neurons,
+// scaling up the rest to compensate.
 //
 // A gradientCalc demands a lot of scratch
 // memory, so it is a good idea to create one
@@ -12,97 +21,131 @@ import "github.com/unixpickle/num-analysis/kahan"
 type gradientCalc struct {
 	n *Network
 
-	hiddenOutputs []float64
-	outputs       []float64
-	inputs        []float64
-	expectedOut   []float64
+	dropout float64
+	rand    *rand.Rand
+
+	inputs []float64
 
-	hiddenOutPartials []float64
+	// layerOutputs stores the outputs of each layer.
+	layerOutputs [][]float64
 
-	OutputPartials [][]float64
-	HiddenPartials [][]float64
+	// When dropout is enabled, dropScales stores the
+	// scale applied to each hidden neuron's output,
+	// and droppedOutputs stores the scaled outputs.
+	dropScales     [][]float64
+	droppedOutputs [][]float64
+
+	// deltas stores the partials of the error with
+	// respect to each neuron's weighted sum.
+	deltas [][]float64
+
+	// Partials stores the partials of the error with
+	// respect to each weight, indexed the same way
+	// as the weights of each layer.
+	Partials [][][]float64
 }
 
-func newGradientCalc(n *Network) *gradientCalc {
+func newGradientCalc(n *Network, dropout float64, r *rand.Rand) *gradientCalc {
 	res := &gradientCalc{
-		n:                 n,
-		hiddenOutputs:     make([]float64, len(n.HiddenWeights)),
-		outputs:           make([]float64, len(n.OutputWeights)),
-		expectedOut:       make([]float64, len(n.OutputWeights)),
-		hiddenOutPartials: make([]float64, len(n.HiddenWeights)),
-		OutputPartials:    make([][]float64, len(n.OutputWeights)),
-		HiddenPartials:    make([][]float64, len(n.HiddenWeights)),
-	}
-
-	for i := range res.OutputPartials {
-		res.OutputPartials[i] = make([]float64, len(res.hiddenOutputs)+1)
+		n:              n,
+		dropout:        dropout,
+		rand:           r,
+		layerOutputs:   make([][]float64, len(n.Layers)),
+		dropScales:     make([][]float64, len(n.Layers)),
+		droppedOutputs: make([][]float64, len(n.Layers)),
+		deltas:         make([][]float64, len(n.Layers)),
+		Partials:       zeroGradient(n),
 	}
-	for i := range res.HiddenPartials {
-		res.HiddenPartials[i] = make([]float64, len(n.Tokens)+1)
+	for i, layer := range n.Layers {
+		res.layerOutputs[i] = make([]float64, len(layer.Weights))
+		res.deltas[i] = make([]float64, len(layer.Weights))
+		if dropout > 0 {
+			res.dropScales[i] = make([]float64, len(layer.Weights))
+			res.droppedOutputs[i] = make([]float64, len(layer.Weights))
+		}
 	}
-
 	return res
 }
 
 func (g *gradientCalc) Compute(inputs []float64, langIdx int) {
 	g.inputs = inputs
-	for j := range g.expectedOut {
-		if j == langIdx {
-			g.expectedOut[j] = 1
-		} else {
-			g.expectedOut[j] = 0
-		}
-	}
-
 	g.computeOutputs()
-	g.computeGradients()
+	g.computeDeltas(langIdx)
+	g.computePartials()
 }
 
 func (g *gradientCalc) computeOutputs() {
-	outputSums := make([]*kahan.Summer64, len(g.outputs))
+	lastIdx := len(g.n.Layers) - 1
+	for i, layer := range g.n.Layers {
+		layer.Apply(g.layerInputs(i), g.layerOutputs[i])
+		if g.dropout == 0 || i == lastIdx {
+			continue
+		}
+		keepScale := 1 / (1 - g.dropout)
+		for j, output := range g.layerOutputs[i] {
+			if g.rand.Float64() < g.dropout {
+				g.dropScales[i][j] = 0
+			} else {
+				g.dropScales[i][j] = keepScale
+			}
+			g.droppedOutputs[i][j] = output * g.dropScales[i][j]
+		}
+	}
+}
 
-	for i := range outputSums {
-		outputSums[i] = kahan.NewSummer64()
-		outputSums[i].Add(g.n.outputBias(i))
+// layerInputs returns the inputs which were fed
+// to a layer.
+func (g *gradientCalc) layerInputs(layerIdx int) []float64 {
+	if layerIdx == 0 {
+		return g.inputs
+	} else if g.dropout > 0 {
+		return g.droppedOutputs[layerIdx-1]
 	}
+	return g.layerOutputs[layerIdx-1]
+}
 
-	for hiddenIndex, hiddenWeights := range g.n.HiddenWeights {
-		hiddenSum := kahan.NewSummer64()
-		for j, input := range g.inputs {
-			hiddenSum.Add(input * hiddenWeights[j])
+func (g *gradientCalc) computeDeltas(langIdx int) {
+	lastIdx := len(g.n.Layers) - 1
+	outLayer := g.n.Layers[lastIdx]
+	for i, output := range g.layerOutputs[lastIdx] {
+		var expected float64
+		if i == langIdx {
+			expected = 1
 		}
-		hiddenSum.Add(g.n.hiddenBias(hiddenIndex))
-
-		hiddenOut := sigmoid(hiddenSum.Sum())
-		g.hiddenOutputs[hiddenIndex] = hiddenOut
-		for j, outSum := range outputSums {
-			weight := g.n.OutputWeights[j][hiddenIndex]
-			outSum.Add(weight * hiddenOut)
+		if outLayer.Activation == SoftmaxActivation {
+			g.deltas[lastIdx][i] = output - expected
+		} else {
+			g.deltas[lastIdx][i] = (output - expected) *
+				outLayer.Activation.derivative(output)
 		}
 	}
 
-	for i, sum := range outputSums {
-		g.outputs[i] = sigmoid(sum.Sum())
+	for layerIdx := lastIdx - 1; layerIdx >= 0; layerIdx-- {
+		activation := g.n.Layers[layerIdx].Activation
+		nextLayer := g.n.Layers[layerIdx+1]
+		nextDeltas := g.deltas[layerIdx+1]
+		for i, output := range g.layerOutputs[layerIdx] {
+			var sum float64
+			for j, delta := range nextDeltas {
+				sum += nextLayer.Weights[j][i] * delta
+			}
+			if g.dropout > 0 {
+				sum *= g.dropScales[layerIdx][i]
+			}
+			g.deltas[layerIdx][i] = sum * activation.derivative(output)
+		}
 	}
 }
 
-func (g *gradientCalc) computeGradients() {
-	for i, output := range g.outputs {
-		gradient := g.OutputPartials[i]
-		diff := output - g.expectedOut[i]
-		sumPartial := (1 - output) * output * diff
-		for j, input := range g.hiddenOutputs {
-			gradient[j] = input * sumPartial
-			g.hiddenOutPartials[j] = g.n.OutputWeights[i][j] * sumPartial
-		}
-		gradient[len(g.hiddenOutputs)] = sumPartial
-	}
-	for i, output := range g.hiddenOutputs {
-		gradient := g.HiddenPartials[i]
-		sumPartial := (1 - output) * output * g.hiddenOutPartials[i]
-		for j, input := range g.inputs {
-			gradient[j] = input * sumPartial
+func (g *gradientCalc) computePartials() {
+	for layerIdx, partials := range g.Partials {
+		inputs := g.layerInputs(layerIdx)
+		for i, delta := range g.deltas[layerIdx] {
+			gradient := partials[i]
+			for j, input := range inputs {
+				gradient[j] = input * delta
+			}
+			gradient[len(inputs)] = delta
 		}
-		gradient[len(g.inputs)] = sumPartial
 	}
 }
@@ -0,0 +1,49 @@
+package neuralnet
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestGradients(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	n := &Network{
+		Layers: []*Layer{
+			randomLayer(r, 5, 4, TanhActivation),
+			randomLayer(r, 4, 3, SigmoidActivation),
+			randomLayer(r, 3, 3, SoftmaxActivation),
+		},
+	}
+	inputs := make([]float64, 5)
+	for i := range inputs {
+		inputs[i] = r.NormFloat64()
+	}
+	loss := func(langIdx int) float64 {
+		return -math.Log(n.outputs(inputs)[langIdx])
+	}
+
+	const epsilon = 1e-6
+	g := newGradientCalc(n, 0, nil)
+	for langIdx := 0; langIdx < 3; langIdx++ {
+		g.Compute(inputs, langIdx)
+		for l, layer := range n.Layers {
+			for i, weights := range layer.Weights {
+				for j, w := range weights {
+					weights[j] = w + epsilon
+					plus := loss(langIdx)
+					weights[j] = w - epsilon
+					minus := loss(langIdx)
+					weights[j] = w
+
+					expected := (plus - minus) / (2 * epsilon)
+					actual := g.Partials[l][i][j]
+					if math.Abs(actual-expected) > 1e-6 {
+						t.Errorf("layer %d weight %d,%d: expected %f but got %f",
+							l, i, j, expected, actual)
+					}
+				}
+			}
+		}
+	}
+}
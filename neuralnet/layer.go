@@ -0,0 +1,152 @@
+package neuralnet
+
+import (
+	"errors"
+	"math"
+	"strconv"
+
+	"github.com/unixpickle/num-analysis/kahan"
+)
+
+// An Activation is a function applied to the
+// weighted sums of a layer's inputs.
+type Activation int
+
+const (
+	SigmoidActivation Activation = iota
+	TanhActivation
+	ReLUActivation
+
+	// SoftmaxActivation normalizes a layer's outputs
+	// into a probability distribution.
+	// It may only be used for the output layer, and
+	// it is trained with a cross-entropy loss.
+	SoftmaxActivation
+)
+
+// ParseActivation parses the name of an Activation,
+// such as "relu" or "softmax".
+func ParseActivation(name string) (Activation, error) {
+	for _, a := range []Activation{SigmoidActivation, TanhActivation, ReLUActivation,
+		SoftmaxActivation} {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, errors.New("unknown activation: " + name)
+}
+
+func (a Activation) String() string {
+	switch a {
+	case SigmoidActivation:
+		return "sigmoid"
+	case TanhActivation:
+		return "tanh"
+	case ReLUActivation:
+		return "relu"
+	case SoftmaxActivation:
+		return "softmax"
+	default:
+		return "Activation(" + strconv.Itoa(int(a)) + ")"
+	}
+}
+
+// derivative computes the derivative of the
+// activation in terms of its output.
+// It is not defined for SoftmaxActivation.
+func (a Activation) derivative(output float64) float64 {
+	switch a {
+	case SigmoidActivation:
+		return output * (1 - output)
+	case TanhActivation:
+		return 1 - output*output
+	case ReLUActivation:
+		if output > 0 {
+			return 1
+		}
+		return 0
+	default:
+		panic("no derivative for activation: " + a.String())
+	}
+}
+
+// A Layer is a fully-connected layer of neurons.
+type Layer struct {
+	// Weights stores a list of weights for each
+	// neuron.
+	// The last weight for each neuron corresponds
+	// to a constant shift, and is not multiplied by
+	// an input's value.
+	Weights [][]float64
+
+	Activation Activation
+}
+
+// Apply computes the layer's outputs for the given
+// inputs and stores them in out.
+func (l *Layer) Apply(inputs, out []float64) {
+	for i, weights := range l.Weights {
+		sum := kahan.NewSummer64()
+		for j, input := range inputs {
+			sum.Add(input * weights[j])
+		}
+		sum.Add(weights[len(inputs)])
+		out[i] = sum.Sum()
+	}
+
+	switch l.Activation {
+	case SoftmaxActivation:
+		max := math.Inf(-1)
+		for _, x := range out {
+			max = math.Max(max, x)
+		}
+		var expSum float64
+		for i, x := range out {
+			out[i] = math.Exp(x - max)
+			expSum += out[i]
+		}
+		for i := range out {
+			out[i] /= expSum
+		}
+	case SigmoidActivation:
+		for i, x := range out {
+			out[i] = sigmoid(x)
+		}
+	case TanhActivation:
+		for i, x := range out {
+			out[i] = math.Tanh(x)
+		}
+	case ReLUActivation:
+		for i, x := range out {
+			out[i] = math.Max(0, x)
+		}
+	default:
+		panic("unknown activation: " + l.Activation.String())
+	}
+}
+
+// InputCount returns the number of inputs to the
+// layer, not including the bias.
+func (l *Layer) InputCount() int {
+	if len(l.Weights) == 0 {
+		return 0
+	}
+	return len(l.Weights[0]) - 1
+}
+
+// Copy creates a deep copy of the layer.
+func (l *Layer) Copy() *Layer {
+	res := &Layer{
+		Weights:    make([][]float64, len(l.Weights)),
+		Activation: l.Activation,
+	}
+	for i, w := range l.Weights {
+		res.Weights[i] = make([]float64, len(w))
+		copy(res.Weights[i], w)
+	}
+	return res
+}
+
+func sigmoid(x float64) float64 {
+	return 1.0 / (1.0 + math.Exp(-x))
+}
@@ -0,0 +1,137 @@
+package neuralnet
+
+import "math"
+
+const (
+	adamBeta1   = 0.9
+	adamBeta2   = 0.999
+	adamEpsilon = 1e-8
+)
+
+// An Optimizer specifies how gradients are turned
+// into updates for a network's weights.
+type Optimizer int
+
+const (
+	// SGDOptimizer takes steps in the direction of
+	// the negative gradient.
+	SGDOptimizer Optimizer = iota
+
+	// MomentumOptimizer adds a fraction of each step
+	// to the next step.
+	MomentumOptimizer
+
+	// AdamOptimizer scales each weight's step using
+	// running estimates of the gradient's first and
+	// second moments.
+	AdamOptimizer
+)
+
+// A Schedule specifies how the learning rate
+// changes over the course of training.
+type Schedule int
+
+const (
+	// ConstantSchedule always uses the step size.
+	ConstantSchedule Schedule = iota
+
+	// StepSchedule halves the learning rate every
+	// TrainerParams.DecayEpochs epochs.
+	StepSchedule
+
+	// CosineSchedule anneals the learning rate from
+	// the step size to 0 along a half cosine wave,
+	// reaching 0 after TrainerParams.MaxIterations
+	// epochs.
+	CosineSchedule
+)
+
+// rate computes the learning rate for an epoch.
+func (s Schedule) rate(p *TrainerParams, stepSize float64, epoch int) float64 {
+	switch s {
+	case StepSchedule:
+		return stepSize * math.Pow(0.5, float64(epoch/p.DecayEpochs))
+	case CosineSchedule:
+		progress := math.Min(1, float64(epoch)/float64(p.MaxIterations))
+		return stepSize * 0.5 * (1 + math.Cos(math.Pi*progress))
+	default:
+		return stepSize
+	}
+}
+
+// An optimizerState applies an Optimizer to a
+// network, keeping track of the per-weight state
+// which the optimizer needs between steps.
+type optimizerState struct {
+	optimizer Optimizer
+	momentum  float64
+
+	// For MomentumOptimizer, first stores the
+	// velocities.
+	// For AdamOptimizer, first and second store the
+	// moment estimates.
+	first  [][][]float64
+	second [][][]float64
+
+	steps int
+}
+
+func newOptimizerState(n *Network, p *TrainerParams) *optimizerState {
+	res := &optimizerState{
+		optimizer: p.Optimizer,
+		momentum:  p.Momentum,
+	}
+	if p.Optimizer != SGDOptimizer {
+		res.first = zeroGradient(n)
+	}
+	if p.Optimizer == AdamOptimizer {
+		res.second = zeroGradient(n)
+	}
+	return res
+}
+
+// step updates the network's weights given the
+// gradient of the error and the learning rate.
+func (o *optimizerState) step(n *Network, grad [][][]float64, rate float64) {
+	o.steps++
+	var firstCorrection, secondCorrection float64
+	if o.optimizer == AdamOptimizer {
+		firstCorrection = 1 - math.Pow(adamBeta1, float64(o.steps))
+		secondCorrection = 1 - math.Pow(adamBeta2, float64(o.steps))
+	}
+
+	for l, layer := range n.Layers {
+		for i, weights := range layer.Weights {
+			for j, g := range grad[l][i] {
+				switch o.optimizer {
+				case MomentumOptimizer:
+					v := o.momentum*o.first[l][i][j] - rate*g
+					o.first[l][i][j] = v
+					weights[j] += v
+				case AdamOptimizer:
+					m := adamBeta1*o.first[l][i][j] + (1-adamBeta1)*g
+					v := adamBeta2*o.second[l][i][j] + (1-adamBeta2)*g*g
+					o.first[l][i][j] = m
+					o.second[l][i][j] = v
+					weights[j] -= rate * (m / firstCorrection) /
+						(math.Sqrt(v/secondCorrection) + adamEpsilon)
+				default:
+					weights[j] -= rate * g
+				}
+			}
+		}
+	}
+}
+
+// zeroGradient creates a gradient of zeroes
+// with the same shape as the network's weights.
+func zeroGradient(n *Network) [][][]float64 {
+	res := make([][][]float64, len(n.Layers))
+	for i, layer := range n.Layers {
+		res[i] = make([][]float64, len(layer.Weights))
+		for j, w := range layer.Weights {
+			res[i][j] = make([]float64, len(w))
+		}
+	}
+	return res
+}
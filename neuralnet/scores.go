@@ -0,0 +1,55 @@
+package neuralnet
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+)
+
+// A scoresFile records the scores of a network
+// after each epoch of training as CSV.
+type scoresFile struct {
+	f *os.File
+	w *csv.Writer
+}
+
+// openScoresFile opens a CSV file for appending
+// scores, writing a header if the file is new.
+// It returns nil if the path is empty.
+func openScoresFile(path string) (*scoresFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	res := &scoresFile{f: f, w: csv.NewWriter(f)}
+	if info.Size() == 0 {
+		res.w.Write([]string{"step_size", "epoch", "cross_score", "training_score"})
+		res.w.Flush()
+	}
+	return res, nil
+}
+
+// Write records the scores for an epoch.
+// The row is flushed immediately, so that it is
+// not lost if training is interrupted.
+func (s *scoresFile) Write(stepSize float64, epoch int, cross, training float64) {
+	s.w.Write([]string{
+		strconv.FormatFloat(stepSize, 'g', -1, 64),
+		strconv.Itoa(epoch),
+		strconv.FormatFloat(cross, 'f', -1, 64),
+		strconv.FormatFloat(training, 'f', -1, 64),
+	})
+	s.w.Flush()
+}
+
+func (s *scoresFile) Close() error {
+	return s.f.Close()
+}
@@ -2,208 +2,459 @@ package neuralnet
 
 import (
 	"log"
+	"math"
 	"math/rand"
+	"runtime"
+	"sync"
 
 	"github.com/unixpickle/whichlang/tokens"
 )
 
 const InitialIterationCount = 200
 
+// gradientShards is the maximum number of pieces
+// each mini-batch is split into for computing the
+// gradient in parallel.
+// The split does not depend on the number of CPUs,
+// so that results are reproducible.
+const gradientShards = 16
+
+// Train trains a Network using TrainerParams
+// from EnvTrainerParams.
 func Train(data map[string][]tokens.Freqs) *Network {
-	ds := NewDataSet(data)
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(data, params)
+}
+
+// TrainParams trains a Network for each of the
+// step sizes in p and returns the one with the
+// best cross-validation score.
+func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) *Network {
+	return train(data, p, &Checkpoint{Params: *p})
+}
+
+// Resume continues the training run which saved a
+// checkpoint.
+// The data must be the same as the data which the
+// run was started with.
+//
+// The parameters which affect the resulting network
+// are taken from the checkpoint, while the logging
+// and checkpointing options are taken from p.
+func Resume(data map[string][]tokens.Freqs, c *Checkpoint, p *TrainerParams) *Network {
+	params := c.Params
+	params.Verbose = p.Verbose
+	params.VerboseSteps = p.VerboseSteps
+	params.CheckpointPath = p.CheckpointPath
+	params.CheckpointInterval = p.CheckpointInterval
+	params.ScoresPath = p.ScoresPath
+	return train(data, &params, c)
+}
 
-	var best *Network
-	var bestCrossScore float64
-	var bestTrainScore float64
+func train(data map[string][]tokens.Freqs, p *TrainerParams, c *Checkpoint) *Network {
+	ds := NewDataSetRand(data, rand.New(rand.NewSource(p.Seed)))
 
-	verbose := verboseFlag()
+	best := c.Best
+	bestCrossScore := c.BestCrossScore
+	bestTrainScore := c.BestTrainScore
 
-	for _, stepSize := range stepSizes() {
+	verbose := p.Verbose
+	if verbose {
+		log.Printf("using seed %d", p.Seed)
+	}
+
+	scores, err := openScoresFile(p.ScoresPath)
+	if err != nil {
+		log.Println("cannot write scores:", err)
+	}
+	if scores != nil {
+		defer scores.Close()
+	}
+
+	saveCheckpoint := func(c *Checkpoint) {
+		if p.CheckpointPath == "" {
+			return
+		}
+		c.Best = best
+		c.BestCrossScore = bestCrossScore
+		c.BestTrainScore = bestTrainScore
+		if err := c.Write(p.CheckpointPath); err != nil {
+			log.Println("cannot write checkpoint:", err)
+		}
+	}
+
+	for idx := c.StepSizeIndex; idx < len(p.StepSizes); idx++ {
+		stepSize := p.StepSizes[idx]
 		if verbose {
 			log.Printf("trying step size %f", stepSize)
 		}
 
-		t := NewTrainer(ds, stepSize, verbose)
-		t.Train(maxIterations())
+		var t *Trainer
+		if idx == c.StepSizeIndex && c.Network != nil {
+			t = c.trainer(ds, p)
+		} else {
+			t = NewTrainer(ds, p, stepSize)
+		}
+		if scores != nil {
+			t.onEpoch = func() {
+				scores.Write(stepSize, t.epoch, ds.CrossScore(t.n), ds.TrainingScore(t.n))
+			}
+		}
+		t.onCheckpoint = func() {
+			if p.CheckpointInterval > 0 && t.epoch%p.CheckpointInterval == 0 {
+				checkpoint := t.checkpoint()
+				checkpoint.StepSizeIndex = idx
+				saveCheckpoint(checkpoint)
+			}
+		}
+		t.Train(p.MaxIterations)
 
 		n := t.Network()
 		if n.containsNaN() {
 			if verbose {
 				log.Printf("got NaN for step size %f", stepSize)
 			}
-			continue
-		}
-		crossScore := ds.CrossScore(n)
-		trainScore := ds.TrainingScore(n)
-		if verbose {
-			log.Printf("stepSize=%f crossScore=%f trainScore=%f", stepSize,
-				crossScore, trainScore)
-		}
-		if (crossScore == bestCrossScore && trainScore >= bestTrainScore) ||
-			best == nil || (crossScore > bestCrossScore) {
-			bestCrossScore = crossScore
-			bestTrainScore = trainScore
-			best = n
+		} else {
+			crossScore := ds.CrossScore(n)
+			trainScore := ds.TrainingScore(n)
+			if verbose {
+				log.Printf("stepSize=%f crossScore=%f trainScore=%f", stepSize,
+					crossScore, trainScore)
+			}
+			if (crossScore == bestCrossScore && trainScore >= bestTrainScore) ||
+				best == nil || (crossScore > bestCrossScore) {
+				bestCrossScore = crossScore
+				bestTrainScore = trainScore
+				best = n
+			}
 		}
+
+		saveCheckpoint(&Checkpoint{Params: *p, StepSizeIndex: idx + 1})
 	}
 
 	return best
 }
 
 type Trainer struct {
-	n *Network
-	d *DataSet
-	g *gradientCalc
+	n   *Network
+	d   *DataSet
+	p   *TrainerParams
+	opt *optimizerState
+
+	// rand is reseeded at the start of each epoch,
+	// so that a resumed run matches one which was
+	// never interrupted.
+	rand *rand.Rand
+
+	// calcs stores one gradientCalc per worker
+	// goroutine.
+	calcs []*gradientCalc
+
+	// grad stores the gradient for the current
+	// mini-batch, which is the sum of shardGrads.
+	grad       [][][]float64
+	shardGrads [][][][]float64
+
+	// samples stores the normalized training
+	// samples.
+	samples []trainingSample
 
 	stepSize float64
-	verbose  bool
+	epoch    int
+
+	// These fields are used to choose the number of
+	// epochs with cross-validation.
+	// The network is evaluated at epoch nextCheck,
+	// and training stops if it has not improved
+	// since it was last evaluated.
+	nextCheck int
+	lastNet   *Network
+	lastCross float64
+	lastTrain float64
+
+	// onEpoch, if set, is called after each epoch.
+	onEpoch func()
+
+	// onCheckpoint, if set, is called after each
+	// epoch when training will continue, at which
+	// point the trainer's state can be saved.
+	onCheckpoint func()
+}
+
+type trainingSample struct {
+	LangIdx int
+	Sample  []float64
 }
 
-func NewTrainer(d *DataSet, stepSize float64, verbose bool) *Trainer {
-	hiddenCount := hiddenSize(len(d.TrainingSamples))
+func NewTrainer(d *DataSet, p *TrainerParams, stepSize float64) *Trainer {
 	n := &Network{
-		Tokens:        d.Tokens(),
-		Langs:         d.Langs(),
-		HiddenWeights: make([][]float64, hiddenCount),
-		OutputWeights: make([][]float64, len(d.TrainingSamples)),
-		InputShift:    -d.MeanFrequency,
-		InputScale:    1 / d.FrequencyStddev,
+		Tokens:     d.Tokens(),
+		Langs:      d.Langs(),
+		InputShift: -d.MeanFrequency,
+		InputScale: 1 / d.FrequencyStddev,
 	}
-	for i := range n.OutputWeights {
-		n.OutputWeights[i] = make([]float64, hiddenCount+1)
-		for j := range n.OutputWeights[i] {
-			n.OutputWeights[i][j] = rand.Float64()*2 - 1
+	if p.Normalization == FeatureNormalization {
+		n.FeatureShifts = make([]float64, len(n.Tokens))
+		n.FeatureScales = make([]float64, len(n.Tokens))
+		for i, mean := range d.FeatureMeans {
+			n.FeatureShifts[i] = -mean
+			n.FeatureScales[i] = 1
+			if stddev := d.FeatureStddevs[i]; stddev > 0 {
+				n.FeatureScales[i] = 1 / stddev
+			}
 		}
 	}
-	for i := range n.HiddenWeights {
-		n.HiddenWeights[i] = make([]float64, len(n.Tokens)+1)
-		for j := range n.HiddenWeights[i] {
-			n.HiddenWeights[i][j] = rand.Float64()*2 - 1
-		}
+
+	r := rand.New(rand.NewSource(p.Seed))
+	inputCount := len(n.Tokens)
+	for _, size := range p.hiddenSizes(len(d.TrainingSamples)) {
+		n.Layers = append(n.Layers, randomLayer(r, inputCount, size, p.Activation))
+		inputCount = size
 	}
-	return &Trainer{
+	n.Layers = append(n.Layers, randomLayer(r, inputCount, len(n.Langs), SoftmaxActivation))
+
+	return newTrainer(d, p, stepSize, n)
+}
+
+// newTrainer creates a Trainer which continues
+// training an existing network.
+func newTrainer(d *DataSet, p *TrainerParams, stepSize float64, n *Network) *Trainer {
+	p = p.withDefaults()
+	res := &Trainer{
 		n:        n,
 		d:        d,
-		g:        newGradientCalc(n),
+		p:        p,
+		opt:      newOptimizerState(n, p),
+		grad:     zeroGradient(n),
 		stepSize: stepSize,
-		verbose:  verbose,
 	}
+	for i, lang := range n.Langs {
+		for _, sample := range d.TrainingSamples[lang] {
+			res.samples = append(res.samples, trainingSample{
+				LangIdx: i,
+				Sample:  n.shiftedInput(sample),
+			})
+		}
+	}
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		calcRand := rand.New(rand.NewSource(0))
+		res.calcs = append(res.calcs, newGradientCalc(n, p.Dropout, calcRand))
+	}
+	return res
 }
 
+// Train trains the network for up to maxIters
+// epochs, using cross-validation to stop early.
+//
+// If the trainer was restored from a Checkpoint,
+// training continues where it left off.
 func (t *Trainer) Train(maxIters int) {
-	iters := InitialIterationCount
-	if iters > maxIters {
-		iters = maxIters
+	if t.nextCheck == 0 {
+		t.nextCheck = minInt(InitialIterationCount, maxIters)
 	}
-	for i := 0; i < iters; i++ {
-		if verboseStepsFlag() {
+	for t.epoch < maxIters {
+		if t.p.VerboseSteps {
 			log.Printf("done %d iterations, cross=%f training=%f",
-				i, t.d.CrossScore(t.n), t.d.TrainingScore(t.n))
+				t.epoch, t.d.CrossScore(t.n), t.d.TrainingScore(t.n))
 		}
 		t.runAllSamples()
-	}
-	if iters == maxIters {
-		return
-	}
-
-	if t.n.containsNaN() {
-		return
-	}
-
-	// Use cross-validation to find the best
-	// number of iterations.
-	crossScore := t.d.CrossScore(t.n)
-	trainScore := t.d.TrainingScore(t.n)
-	lastNet := t.n.Copy()
-
-	for {
-		if t.verbose {
-			log.Printf("current scores: cross=%f train=%f iters=%d",
-				crossScore, trainScore, iters)
+		if t.onEpoch != nil {
+			t.onEpoch()
 		}
 
-		nextAmount := iters
-		if nextAmount+iters > maxIters {
-			nextAmount = maxIters - iters
-		}
-		for i := 0; i < nextAmount; i++ {
-			if verboseStepsFlag() {
-				log.Printf("done %d iterations, cross=%f training=%f",
-					i+iters, t.d.CrossScore(t.n), t.d.TrainingScore(t.n))
-			}
-			t.runAllSamples()
-			if t.n.containsNaN() {
-				break
+		if t.n.containsNaN() {
+			if t.lastNet != nil {
+				t.n = t.lastNet
 			}
+			return
 		}
-		iters += nextAmount
-
-		if t.n.containsNaN() {
-			t.n = lastNet
-			break
+		if t.epoch == t.nextCheck && !t.checkProgress(maxIters) {
+			return
 		}
 
-		newCrossScore := t.d.CrossScore(t.n)
-		newTrainScore := t.d.TrainingScore(t.n)
-		if (newCrossScore == crossScore && newTrainScore == trainScore) ||
-			newCrossScore < crossScore {
-			t.n = lastNet
-			return
+		if t.onCheckpoint != nil && t.epoch < maxIters {
+			t.onCheckpoint()
 		}
+	}
+}
 
-		crossScore = newCrossScore
-		trainScore = newTrainScore
+// checkProgress evaluates the network and returns
+// false if it has not improved since the last
+// check, in which case the previous network is
+// restored.
+func (t *Trainer) checkProgress(maxIters int) bool {
+	crossScore := t.d.CrossScore(t.n)
+	trainScore := t.d.TrainingScore(t.n)
+	if t.lastNet != nil && ((crossScore == t.lastCross && trainScore == t.lastTrain) ||
+		crossScore < t.lastCross) {
+		t.n = t.lastNet
+		return false
+	}
 
-		if iters == maxIters {
-			return
-		}
-		lastNet = t.n.Copy()
+	if t.p.Verbose {
+		log.Printf("current scores: cross=%f train=%f iters=%d",
+			crossScore, trainScore, t.epoch)
 	}
+
+	t.lastNet = t.n.Copy()
+	t.lastCross = crossScore
+	t.lastTrain = trainScore
+	t.nextCheck = minInt(t.epoch*2, maxIters)
+	return true
 }
 
 func (t *Trainer) Network() *Network {
 	return t.n
 }
 
+// runAllSamples runs one epoch of mini-batch
+// gradient descent.
 func (t *Trainer) runAllSamples() {
-	var samples []struct {
-		LangIdx int
-		Sample  []float64
+	samples := t.samples
+	rate := t.p.Schedule.rate(t.p, t.stepSize, t.epoch)
+	t.rand = rand.New(rand.NewSource(t.p.Seed + int64(t.epoch) + 1))
+	perm := t.rand.Perm(len(samples))
+	for len(perm) > 0 {
+		batchSize := t.p.BatchSize
+		if batchSize > len(perm) {
+			batchSize = len(perm)
+		}
+		batch := perm[:batchSize]
+		t.computeGradient(len(batch), func(g *gradientCalc, idx int) {
+			sample := samples[batch[idx]]
+			g.Compute(sample.Sample, sample.LangIdx)
+		})
+		t.descendBatch(batchSize, rate)
+		perm = perm[batchSize:]
+	}
+	t.epoch++
+}
+
+// computeGradient sets t.grad to the sum of the
+// gradients for a mini-batch.
+//
+// The batch is split into contiguous shards, which
+// are processed concurrently by the trainer's
+// gradientCalcs.
+// The compute function is called to compute the
+// gradient for the sample at a given index in the
+// batch.
+func (t *Trainer) computeGradient(batchSize int, compute func(g *gradientCalc, idx int)) {
+	shardSize := (batchSize + gradientShards - 1) / gradientShards
+	numShards := (batchSize + shardSize - 1) / shardSize
+	for len(t.shardGrads) < numShards {
+		t.shardGrads = append(t.shardGrads, zeroGradient(t.n))
 	}
 
-	for i, lang := range t.n.Langs {
-		var sample struct {
-			LangIdx int
-			Sample  []float64
+	// Each shard gets its own seed for dropout, so
+	// the result doesn't depend on which goroutine
+	// handles which shard.
+	seeds := make([]int64, numShards)
+	if t.p.Dropout > 0 {
+		for i := range seeds {
+			seeds[i] = t.rand.Int63()
 		}
-		sample.LangIdx = i
+	}
 
-		trainingSamples := t.d.NormalTrainingSamples[lang]
-		for _, s := range trainingSamples {
-			sample.Sample = s
-			samples = append(samples, sample)
-		}
+	shards := make(chan int, numShards)
+	for i := 0; i < numShards; i++ {
+		shards <- i
+	}
+	close(shards)
+
+	var wg sync.WaitGroup
+	for _, g := range t.calcs[:minInt(len(t.calcs), numShards)] {
+		wg.Add(1)
+		go func(g *gradientCalc) {
+			defer wg.Done()
+			for shard := range shards {
+				grad := t.shardGrads[shard]
+				clearGradient(grad)
+				g.rand.Seed(seeds[shard])
+				end := minInt(batchSize, (shard+1)*shardSize)
+				for idx := shard * shardSize; idx < end; idx++ {
+					compute(g, idx)
+					addGradient(grad, g.Partials)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	clearGradient(t.grad)
+	for _, grad := range t.shardGrads[:numShards] {
+		addGradient(t.grad, grad)
 	}
+}
 
-	perm := rand.Perm(len(samples))
-	for _, i := range perm {
-		t.descendSample(samples[i].Sample, samples[i].LangIdx)
+// descendBatch averages the accumulated gradient
+// over the batch, adds L2 weight decay, and takes
+// a step with the optimizer.
+func (t *Trainer) descendBatch(batchSize int, rate float64) {
+	scale := 1 / float64(batchSize)
+	for l, layer := range t.grad {
+		for i, partials := range layer {
+			weights := t.n.Layers[l].Weights[i]
+			biasIdx := len(partials) - 1
+			for j := range partials {
+				partials[j] *= scale
+				if j != biasIdx {
+					partials[j] += t.p.WeightDecay * weights[j]
+				}
+			}
+		}
 	}
+	t.opt.step(t.n, t.grad, rate)
 }
 
-// descendSample performs gradient descent to
-// reduce the output error for a given sample.
-func (t *Trainer) descendSample(inputs []float64, langIdx int) {
-	t.g.Compute(inputs, langIdx)
+// randomLayer creates a layer with uniformly
+// random weights, scaled by the number of inputs
+// so that the weighted sums start out with
+// roughly unit variance.
+func randomLayer(r *rand.Rand, inputCount, outputCount int, a Activation) *Layer {
+	scale := math.Sqrt(3 / float64(inputCount+1))
+	if a == ReLUActivation {
+		scale *= math.Sqrt2
+	}
+	res := &Layer{
+		Weights:    make([][]float64, outputCount),
+		Activation: a,
+	}
+	for i := range res.Weights {
+		res.Weights[i] = make([]float64, inputCount+1)
+		for j := range res.Weights[i] {
+			res.Weights[i][j] = (r.Float64()*2 - 1) * scale
+		}
+	}
+	return res
+}
 
-	for i, partials := range t.g.HiddenPartials {
-		for j, partial := range partials {
-			t.n.HiddenWeights[i][j] -= partial * t.stepSize
+func clearGradient(grad [][][]float64) {
+	for _, layer := range grad {
+		for _, neuron := range layer {
+			for i := range neuron {
+				neuron[i] = 0
+			}
 		}
 	}
-	for i, partials := range t.g.OutputPartials {
-		for j, partial := range partials {
-			t.n.OutputWeights[i][j] -= partial * t.stepSize
+}
+
+func addGradient(dest, source [][][]float64) {
+	for l, layer := range source {
+		for i, partials := range layer {
+			for j, partial := range partials {
+				dest[l][i][j] += partial
+			}
 		}
 	}
 }
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,62 @@
+package neuralnet
+
+import (
+	"bytes"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func TestTrainReproducible(t *testing.T) {
+	data := testSamples()
+	p := testParams()
+
+	oldProcs := runtime.GOMAXPROCS(1)
+	defer runtime.GOMAXPROCS(oldProcs)
+	expected := TrainParams(data, p).Encode()
+
+	for _, procs := range []int{2, 7} {
+		runtime.GOMAXPROCS(procs)
+		if actual := TrainParams(data, p).Encode(); !bytes.Equal(actual, expected) {
+			t.Errorf("GOMAXPROCS=%d gave a different network", procs)
+		}
+	}
+}
+
+func testParams() *TrainerParams {
+	return &TrainerParams{
+		StepSizes:     []float64{1e-2},
+		MaxIterations: 12,
+		HiddenSizes:   []int{6},
+		Activation:    TanhActivation,
+		Optimizer:     AdamOptimizer,
+		BatchSize:     40,
+		Dropout:       0.2,
+		Normalization: FeatureNormalization,
+		Seed:          1,
+	}
+}
+
+// testSamples creates samples for three languages,
+// each of which favors different tokens.
+func testSamples() map[string][]tokens.Freqs {
+	r := rand.New(rand.NewSource(1))
+	res := map[string][]tokens.Freqs{}
+	for langIdx, lang := range []string{"A", "B", "C"} {
+		for i := 0; i < 40; i++ {
+			counts := tokens.Counts{}
+			for tok := 0; tok < 8; tok++ {
+				count := r.Intn(5)
+				if tok%3 == langIdx {
+					count += 5
+				}
+				counts["tok"+strconv.Itoa(tok)] = count
+			}
+			res[lang] = append(res[lang], counts.Freqs())
+		}
+	}
+	return res
+}
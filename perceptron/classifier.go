@@ -0,0 +1,338 @@
+// Package perceptron implements online linear
+// classifiers which learn from one sample at a
+// time and can keep learning after being decoded.
+package perceptron
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"sync"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// An Algorithm decides how a Classifier updates its
+// weights for each sample.
+type Algorithm int
+
+const (
+	// Perceptron adds a sample to the weights of
+	// its language and subtracts it from the weights
+	// of the predicted language whenever the
+	// prediction is wrong.
+	Perceptron Algorithm = iota
+
+	// PassiveAggressive makes the smallest update
+	// (capped by the aggressiveness) which gives the
+	// correct language a margin of 1 over every other
+	// language.
+	// This is the PA-I algorithm.
+	PassiveAggressive
+)
+
+// ParseAlgorithm parses the name of an Algorithm,
+// either "perceptron" or "pa".
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch name {
+	case "perceptron":
+		return Perceptron, nil
+	case "pa":
+		return PassiveAggressive, nil
+	default:
+		return 0, errors.New("unknown algorithm: " + name)
+	}
+}
+
+func (a Algorithm) String() string {
+	switch a {
+	case Perceptron:
+		return "perceptron"
+	case PassiveAggressive:
+		return "pa"
+	default:
+		return "Algorithm(" + strconv.Itoa(int(a)) + ")"
+	}
+}
+
+// A Classifier is a multiclass linear classifier
+// which classifies with the average of its weights
+// over every training step.
+//
+// Token frequencies are scaled to unit length
+// before they are weighted.
+//
+// The weights take 16*len(Langs)*len(Tokens) bytes,
+// so MaxTokens bounds their memory no matter how
+// many samples are trained on.
+//
+// Update must not be called concurrently with any
+// other method.
+type Classifier struct {
+	Algorithm      Algorithm
+	Aggressiveness float64
+
+	Langs  []string
+	Tokens []string
+
+	// MaxTokens is the most tokens the classifier
+	// will learn weights for.
+	// Once it is reached, new tokens are ignored.
+	// If it is 0, there is no limit.
+	MaxTokens int
+
+	// Weights and Biases are the current weights,
+	// indexed like Langs and Tokens.
+	Weights [][]float64
+	Biases  []float64
+
+	// WeightSums and BiasSums accumulate each update
+	// times the number of steps before it, so that
+	// the averaged weights are W - WeightSums/Steps.
+	WeightSums [][]float64
+	BiasSums   []float64
+
+	// Steps is the number of samples trained on.
+	Steps int
+
+	tokenIndicesOnce sync.Once
+	tokenIndices     map[string]int
+}
+
+// NewClassifier creates an untrained Classifier.
+func NewClassifier(a Algorithm, aggressiveness float64) *Classifier {
+	return &Classifier{Algorithm: a, Aggressiveness: aggressiveness}
+}
+
+func DecodeClassifier(d []byte) (*Classifier, error) {
+	var c Classifier
+	if err := json.Unmarshal(d, &c); err != nil {
+		return nil, err
+	}
+	if len(c.Weights) != len(c.Langs) || len(c.WeightSums) != len(c.Langs) ||
+		len(c.Biases) != len(c.Langs) || len(c.BiasSums) != len(c.Langs) {
+		return nil, errors.New("mismatched language count")
+	}
+	for i := range c.Langs {
+		if len(c.Weights[i]) != len(c.Tokens) || len(c.WeightSums[i]) != len(c.Tokens) {
+			return nil, errors.New("mismatched token count")
+		}
+	}
+	return &c, nil
+}
+
+func (c *Classifier) Classify(f tokens.Freqs) string {
+	s := c.features(f)
+	var bestLang string
+	bestScore := math.Inf(-1)
+	for i, lang := range c.Langs {
+		score := c.Biases[i]
+		sumScore := c.BiasSums[i]
+		for j, idx := range s.indices {
+			if idx >= 0 {
+				score += s.values[j] * c.Weights[i][idx]
+				sumScore += s.values[j] * c.WeightSums[i][idx]
+			}
+		}
+		if c.Steps > 0 {
+			score -= sumScore / float64(c.Steps)
+		}
+		if score > bestScore {
+			bestScore = score
+			bestLang = lang
+		}
+	}
+	return bestLang
+}
+
+// Update trains the classifier on one sample.
+// The language may be one the classifier has not
+// seen before.
+func (c *Classifier) Update(lang string, f tokens.Freqs) {
+	langIdx := c.langIndex(lang)
+	s := c.features(f)
+
+	scores := make([]float64, len(c.Langs))
+	for i := range c.Langs {
+		scores[i] = c.Biases[i]
+		for j, idx := range s.indices {
+			if idx >= 0 {
+				scores[i] += s.values[j] * c.Weights[i][idx]
+			}
+		}
+	}
+
+	var step float64
+	var rival int
+	switch c.Algorithm {
+	case Perceptron:
+		rival = argmax(scores, -1)
+		if rival != langIdx {
+			step = 1
+		}
+	case PassiveAggressive:
+		rival = argmax(scores, langIdx)
+		if rival >= 0 {
+			loss := 1 - scores[langIdx] + scores[rival]
+			if loss > 0 {
+				// The features have unit length, plus
+				// a bias feature of 1.
+				sqNorm := 1.0
+				if len(s.values) > 0 {
+					sqNorm++
+				}
+				step = math.Min(c.Aggressiveness, loss/(2*sqNorm))
+			}
+		}
+	default:
+		panic("unknown algorithm: " + c.Algorithm.String())
+	}
+
+	if step != 0 {
+		for j, idx := range s.indices {
+			if idx < 0 && (c.MaxTokens == 0 || len(c.Tokens) < c.MaxTokens) {
+				s.indices[j] = c.addToken(s.tokens[j])
+			}
+		}
+		c.addUpdate(langIdx, s, step)
+		c.addUpdate(rival, s, -step)
+	}
+	c.Steps++
+}
+
+// UpdatePasses trains the classifier with the given
+// number of passes over samples, calling open to
+// read the samples again for each pass.
+func (c *Classifier) UpdatePasses(open func() (tokens.SampleReader, error), passes int) error {
+	for i := 0; i < passes; i++ {
+		r, err := open()
+		if err != nil {
+			return err
+		}
+		if err := c.UpdateReader(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateReader trains the classifier on every
+// sample from a tokens.SampleReader, in order.
+func (c *Classifier) UpdateReader(r tokens.SampleReader) error {
+	for {
+		lang, f, err := r.ReadSample()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		c.Update(lang, f)
+	}
+}
+
+func (c *Classifier) Encode() []byte {
+	res, _ := json.Marshal(c)
+	return res
+}
+
+func (c *Classifier) Languages() []string {
+	return c.Langs
+}
+
+// A sample stores the tokens of a document, their
+// indices in Tokens (or -1 for unknown tokens), and
+// their frequencies scaled to unit length.
+type sample struct {
+	tokens  []string
+	indices []int
+	values  []float64
+}
+
+func (c *Classifier) features(f tokens.Freqs) *sample {
+	c.tokenIndicesOnce.Do(func() {
+		c.tokenIndices = map[string]int{}
+		for i, token := range c.Tokens {
+			c.tokenIndices[token] = i
+		}
+	})
+	res := &sample{}
+	var sqNorm float64
+	for token, freq := range f {
+		if token == "" || freq == 0 {
+			continue
+		}
+		idx, ok := c.tokenIndices[token]
+		if !ok {
+			idx = -1
+		}
+		res.tokens = append(res.tokens, token)
+		res.indices = append(res.indices, idx)
+		res.values = append(res.values, freq)
+		sqNorm += freq * freq
+	}
+	scale := 1 / math.Sqrt(sqNorm)
+	for i := range res.values {
+		res.values[i] *= scale
+	}
+	return res
+}
+
+// langIndex returns the index of a language,
+// adding it if necessary.
+func (c *Classifier) langIndex(lang string) int {
+	for i, l := range c.Langs {
+		if l == lang {
+			return i
+		}
+	}
+	c.Langs = append(c.Langs, lang)
+	c.Weights = append(c.Weights, make([]float64, len(c.Tokens)))
+	c.WeightSums = append(c.WeightSums, make([]float64, len(c.Tokens)))
+	c.Biases = append(c.Biases, 0)
+	c.BiasSums = append(c.BiasSums, 0)
+	return len(c.Langs) - 1
+}
+
+// addToken adds a token with zero weights and
+// returns its index.
+func (c *Classifier) addToken(token string) int {
+	c.Tokens = append(c.Tokens, token)
+	for i := range c.Langs {
+		c.Weights[i] = append(c.Weights[i], 0)
+		c.WeightSums[i] = append(c.WeightSums[i], 0)
+	}
+	c.tokenIndices[token] = len(c.Tokens) - 1
+	return len(c.Tokens) - 1
+}
+
+// addUpdate adds a scaled sample to the weights of
+// a language.
+func (c *Classifier) addUpdate(langIdx int, s *sample, scale float64) {
+	steps := float64(c.Steps)
+	weights := c.Weights[langIdx]
+	sums := c.WeightSums[langIdx]
+	for j, idx := range s.indices {
+		if idx < 0 {
+			continue
+		}
+		weights[idx] += scale * s.values[j]
+		sums[idx] += steps * scale * s.values[j]
+	}
+	c.Biases[langIdx] += scale
+	c.BiasSums[langIdx] += steps * scale
+}
+
+// argmax returns the index of the largest score,
+// skipping the index exclude.
+// It returns -1 if there are no other scores.
+func argmax(scores []float64, exclude int) int {
+	res := -1
+	for i, score := range scores {
+		if i != exclude && (res < 0 || score > scores[res]) {
+			res = i
+		}
+	}
+	return res
+}
@@ -0,0 +1,100 @@
+package perceptron
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+func TestAveragedWeights(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	langs := []string{"A", "B", "C"}
+	for _, algorithm := range []Algorithm{Perceptron, PassiveAggressive} {
+		c := NewClassifier(algorithm, 0.5)
+
+		// Sum the weights after every step.
+		weightSums := map[string][]float64{}
+		biasSums := make([]float64, len(langs))
+		for step := 0; step < 50; step++ {
+			c.Update(langs[r.Intn(len(langs))], randomFreqs(r))
+			for i := range c.Langs {
+				for j, token := range c.Tokens {
+					if weightSums[token] == nil {
+						weightSums[token] = make([]float64, len(langs))
+					}
+					weightSums[token][i] += c.Weights[i][j]
+				}
+				biasSums[i] += c.Biases[i]
+			}
+		}
+
+		f := randomFreqs(r)
+		s := c.features(f)
+		bestScore := math.Inf(-1)
+		var bestLang string
+		for i, lang := range c.Langs {
+			expectedBias := biasSums[i] / float64(c.Steps)
+			actualBias := c.Biases[i] - c.BiasSums[i]/float64(c.Steps)
+			if math.Abs(actualBias-expectedBias) > 1e-8 {
+				t.Errorf("%s: %s: expected bias %f but got %f", algorithm, lang,
+					expectedBias, actualBias)
+			}
+			score := expectedBias
+			for j, token := range c.Tokens {
+				expected := weightSums[token][i] / float64(c.Steps)
+				actual := c.Weights[i][j] - c.WeightSums[i][j]/float64(c.Steps)
+				if math.Abs(actual-expected) > 1e-8 {
+					t.Errorf("%s: %s: %s: expected weight %f but got %f", algorithm,
+						lang, token, expected, actual)
+				}
+			}
+			for j, idx := range s.indices {
+				if idx >= 0 {
+					score += s.values[j] * weightSums[c.Tokens[idx]][i] / float64(c.Steps)
+				}
+			}
+			if score > bestScore {
+				bestScore = score
+				bestLang = lang
+			}
+		}
+		if actual := c.Classify(f); actual != bestLang {
+			t.Errorf("%s: expected %s but got %s", algorithm, bestLang, actual)
+		}
+	}
+}
+
+func TestMaxTokens(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	p := &TrainerParams{Algorithm: Perceptron, Aggressiveness: 1, Passes: 2, MaxTokens: 5}
+	data := map[string][]tokens.Freqs{}
+	for _, lang := range []string{"A", "B"} {
+		for i := 0; i < 20; i++ {
+			data[lang] = append(data[lang], randomFreqs(r))
+		}
+	}
+	c, err := DecodeClassifier(TrainParams(data, p).Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Tokens) != 5 {
+		t.Fatalf("expected 5 tokens but got %d", len(c.Tokens))
+	}
+	for i := 0; i < 20; i++ {
+		c.Update("A", randomFreqs(r))
+	}
+	if len(c.Tokens) != 5 {
+		t.Errorf("expected 5 tokens after decoding but got %d", len(c.Tokens))
+	}
+}
+
+func randomFreqs(r *rand.Rand) tokens.Freqs {
+	counts := tokens.Counts{}
+	for i := 0; i < 5; i++ {
+		counts["tok"+strconv.Itoa(r.Intn(20))] = r.Intn(5) + 1
+	}
+	return counts.Freqs()
+}
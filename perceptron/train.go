@@ -0,0 +1,153 @@
+package perceptron
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"strconv"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+const (
+	DefaultAggressiveness = 1.0
+	DefaultPasses         = 2
+	DefaultMaxTokens      = 100000
+)
+
+// These environment variables specify
+// various parameters for the trainer.
+const (
+	// You may set this to "perceptron" (the default)
+	// or "pa" for passive-aggressive updates.
+	AlgorithmEnvVar = "PERCEPTRON_ALGORITHM"
+
+	// The largest step that a passive-aggressive
+	// update may take.
+	// Smaller values are more robust to mislabeled
+	// samples.
+	AggressivenessEnvVar = "PERCEPTRON_AGGRESSIVENESS"
+
+	// The number of passes over the training data.
+	PassesEnvVar = "PERCEPTRON_PASSES"
+
+	// The most tokens to learn weights for, or 0 for
+	// no limit.
+	// The weights take 16 bytes per token for every
+	// language.
+	MaxTokensEnvVar = "PERCEPTRON_MAX_TOKENS"
+)
+
+// TrainerParams specifies parameters for the
+// online trainer.
+type TrainerParams struct {
+	Algorithm      Algorithm
+	Aggressiveness float64
+	Passes         int
+	MaxTokens      int
+}
+
+// EnvTrainerParams generates TrainerParams
+// by reading environment variables.
+// If an environment variable is incorrectly
+// formatted, this returns an error.
+func EnvTrainerParams() (*TrainerParams, error) {
+	res := &TrainerParams{
+		Algorithm:      Perceptron,
+		Aggressiveness: DefaultAggressiveness,
+		Passes:         DefaultPasses,
+		MaxTokens:      DefaultMaxTokens,
+	}
+	if val := os.Getenv(AlgorithmEnvVar); val != "" {
+		a, err := ParseAlgorithm(val)
+		if err != nil {
+			return nil, err
+		}
+		res.Algorithm = a
+	}
+	if val := os.Getenv(AggressivenessEnvVar); val != "" {
+		aggressiveness, err := strconv.ParseFloat(val, 64)
+		if err != nil || aggressiveness <= 0 {
+			return nil, errors.New("invalid aggressiveness: " + val)
+		}
+		res.Aggressiveness = aggressiveness
+	}
+	if val := os.Getenv(PassesEnvVar); val != "" {
+		passes, err := strconv.Atoi(val)
+		if err != nil || passes < 1 {
+			return nil, errors.New("invalid pass count: " + val)
+		}
+		res.Passes = passes
+	}
+	if val := os.Getenv(MaxTokensEnvVar); val != "" {
+		maxTokens, err := strconv.Atoi(val)
+		if err != nil || maxTokens < 0 {
+			return nil, errors.New("invalid max tokens: " + val)
+		}
+		res.MaxTokens = maxTokens
+	}
+	return res, nil
+}
+
+// Train trains a Classifier using TrainerParams
+// from EnvTrainerParams.
+func Train(data map[string][]tokens.Freqs) *Classifier {
+	params, err := EnvTrainerParams()
+	if err != nil {
+		panic(err)
+	}
+	return TrainParams(data, params)
+}
+
+// TrainParams trains a Classifier by making
+// p.Passes passes over the samples, shuffling them
+// before each pass.
+func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
+	var langs []string
+	var samples []tokens.Freqs
+	for lang, langSamples := range data {
+		for _, sample := range langSamples {
+			langs = append(langs, lang)
+			samples = append(samples, sample)
+		}
+	}
+
+	res := newClassifierParams(p)
+	for i := 0; i < p.Passes; i++ {
+		for _, idx := range rand.Perm(len(samples)) {
+			res.Update(langs[idx], samples[idx])
+		}
+	}
+	return res
+}
+
+// TrainReader trains a Classifier with a single
+// pass over the samples from r.
+// To make more passes, call UpdateReader on the
+// result with a new reader.
+func TrainReader(r tokens.SampleReader, p *TrainerParams) (*Classifier, error) {
+	res := newClassifierParams(p)
+	if err := res.UpdateReader(r); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// TrainStream trains a Classifier by making
+// p.Passes passes over samples, calling open to
+// read the samples again for each pass.
+// Unlike TrainParams, it never holds more than one
+// sample in memory.
+func TrainStream(open func() (tokens.SampleReader, error), p *TrainerParams) (*Classifier, error) {
+	res := newClassifierParams(p)
+	if err := res.UpdatePasses(open, p.Passes); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func newClassifierParams(p *TrainerParams) *Classifier {
+	res := NewClassifier(p.Algorithm, p.Aggressiveness)
+	res.MaxTokens = p.MaxTokens
+	return res
+}
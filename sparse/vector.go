@@ -0,0 +1,182 @@
+// Package sparse implements sparse vectors for
+// classifiers whose inputs are token frequencies,
+// most of which are zero for any given document.
+package sparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/unixpickle/whichlang/tokens"
+)
+
+// A Vector is a sparse vector which stores the
+// indices and values of its non-zero components.
+// Indices are sorted in ascending order.
+type Vector struct {
+	Indices []int
+	Values  []float64
+}
+
+// NewVector creates a sparse Vector from the
+// non-zero components of a dense vector.
+func NewVector(dense []float64) Vector {
+	var res Vector
+	for i, x := range dense {
+		if x != 0 {
+			res.Indices = append(res.Indices, i)
+			res.Values = append(res.Values, x)
+		}
+	}
+	return res
+}
+
+// FreqsVector creates a Vector from the frequencies
+// of the tokens in indices, which maps tokens to
+// their corresponding vector components.
+// Tokens missing from indices are ignored.
+func FreqsVector(indices map[string]int, f tokens.Freqs) Vector {
+	var res Vector
+	for tok, freq := range f {
+		if idx, ok := indices[tok]; ok && freq != 0 {
+			res.Indices = append(res.Indices, idx)
+			res.Values = append(res.Values, freq)
+		}
+	}
+	sort.Sort(vectorSorter(res))
+	return res
+}
+
+// Dense returns the dense equivalent of v, given
+// the dimension of the vector space.
+func (v Vector) Dense(size int) []float64 {
+	res := make([]float64, size)
+	for i, idx := range v.Indices {
+		res[idx] = v.Values[i]
+	}
+	return res
+}
+
+// Dot computes the dot product of two vectors.
+func (v Vector) Dot(v1 Vector) float64 {
+	var res float64
+	var i, j int
+	for i < len(v.Indices) && j < len(v1.Indices) {
+		if v.Indices[i] < v1.Indices[j] {
+			i++
+		} else if v.Indices[i] > v1.Indices[j] {
+			j++
+		} else {
+			res += v.Values[i] * v1.Values[j]
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+// ForUnion calls f for every component that is
+// non-zero in either v or v1, passing the values
+// of the component in v and v1, respectively.
+func (v Vector) ForUnion(v1 Vector, f func(x, y float64)) {
+	var i, j int
+	for i < len(v.Indices) || j < len(v1.Indices) {
+		if j == len(v1.Indices) || (i < len(v.Indices) && v.Indices[i] < v1.Indices[j]) {
+			f(v.Values[i], 0)
+			i++
+		} else if i == len(v.Indices) || v.Indices[i] > v1.Indices[j] {
+			f(0, v1.Values[j])
+			j++
+		} else {
+			f(v.Values[i], v1.Values[j])
+			i++
+			j++
+		}
+	}
+}
+
+// DotDense computes the dot product of v and a
+// dense vector.
+func (v Vector) DotDense(dense []float64) float64 {
+	var res float64
+	for i, idx := range v.Indices {
+		res += v.Values[i] * dense[idx]
+	}
+	return res
+}
+
+// Norm returns the Euclidean norm of v.
+func (v Vector) Norm() float64 {
+	return math.Sqrt(v.Dot(v))
+}
+
+// Scale scales v in place and returns it.
+func (v Vector) Scale(s float64) Vector {
+	for i := range v.Values {
+		v.Values[i] *= s
+	}
+	return v
+}
+
+// Copy creates a deep copy of v.
+func (v Vector) Copy() Vector {
+	res := Vector{
+		Indices: make([]int, len(v.Indices)),
+		Values:  make([]float64, len(v.Values)),
+	}
+	copy(res.Indices, v.Indices)
+	copy(res.Values, v.Values)
+	return res
+}
+
+// UnmarshalJSON decodes a Vector from either its
+// sparse representation or a dense JSON array,
+// which is how vectors were once stored.
+func (v *Vector) UnmarshalJSON(d []byte) error {
+	trimmed := bytes.TrimSpace(d)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var dense []float64
+		if err := json.Unmarshal(trimmed, &dense); err != nil {
+			return err
+		}
+		*v = NewVector(dense)
+		return nil
+	}
+
+	var raw struct {
+		Indices []int
+		Values  []float64
+	}
+	if err := json.Unmarshal(trimmed, &raw); err != nil {
+		return err
+	}
+	if len(raw.Indices) != len(raw.Values) {
+		return errors.New("mismatching sparse vector lengths")
+	}
+	for i, idx := range raw.Indices {
+		if idx < 0 || (i > 0 && idx <= raw.Indices[i-1]) {
+			return errors.New("sparse vector indices must be increasing")
+		}
+	}
+	v.Indices = raw.Indices
+	v.Values = raw.Values
+	return nil
+}
+
+type vectorSorter Vector
+
+func (v vectorSorter) Len() int {
+	return len(v.Indices)
+}
+
+func (v vectorSorter) Less(i, j int) bool {
+	return v.Indices[i] < v.Indices[j]
+}
+
+func (v vectorSorter) Swap(i, j int) {
+	v.Indices[i], v.Indices[j] = v.Indices[j], v.Indices[i]
+	v.Values[i], v.Values[j] = v.Values[j], v.Values[i]
+}
@@ -0,0 +1,120 @@
+package sparse
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+const (
+	benchmarkDimension = 20000
+	benchmarkDensity   = 0.05
+)
+
+func TestVectorDot(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		d1 := randomDense(100, 0.3)
+		d2 := randomDense(100, 0.3)
+		var expected float64
+		for j, x := range d1 {
+			expected += x * d2[j]
+		}
+		v1, v2 := NewVector(d1), NewVector(d2)
+		if actual := v1.Dot(v2); math.Abs(actual-expected) > 1e-8 {
+			t.Errorf("expected Dot %f but got %f", expected, actual)
+		}
+		if actual := v1.DotDense(d2); math.Abs(actual-expected) > 1e-8 {
+			t.Errorf("expected DotDense %f but got %f", expected, actual)
+		}
+	}
+}
+
+func TestVectorJSON(t *testing.T) {
+	var legacy Vector
+	if err := json.Unmarshal([]byte("[0, 1.5, 0, 0, -2]"), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	expected := Vector{Indices: []int{1, 4}, Values: []float64{1.5, -2}}
+	if !vectorsEqual(legacy, expected) {
+		t.Fatal("unexpected legacy decoding:", legacy)
+	}
+
+	data, err := json.Marshal(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Vector
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !vectorsEqual(decoded, expected) {
+		t.Fatal("unexpected decoding:", decoded)
+	}
+
+	var invalid Vector
+	if json.Unmarshal([]byte(`{"Indices":[3,1],"Values":[1,2]}`), &invalid) == nil {
+		t.Error("expected error for unsorted indices")
+	}
+}
+
+func BenchmarkDenseDot(b *testing.B) {
+	v1 := randomDense(benchmarkDimension, benchmarkDensity)
+	v2 := randomDense(benchmarkDimension, benchmarkDensity)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var sum float64
+		for j, x := range v1 {
+			sum += x * v2[j]
+		}
+	}
+}
+
+func BenchmarkSparseDot(b *testing.B) {
+	v1 := NewVector(randomDense(benchmarkDimension, benchmarkDensity))
+	v2 := NewVector(randomDense(benchmarkDimension, benchmarkDensity))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		v1.Dot(v2)
+	}
+}
+
+func BenchmarkDenseJSON(b *testing.B) {
+	v := randomDense(benchmarkDimension, benchmarkDensity)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, _ := json.Marshal(v)
+		b.SetBytes(int64(len(data)))
+	}
+}
+
+func BenchmarkSparseJSON(b *testing.B) {
+	v := NewVector(randomDense(benchmarkDimension, benchmarkDensity))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		data, _ := json.Marshal(v)
+		b.SetBytes(int64(len(data)))
+	}
+}
+
+func randomDense(size int, density float64) []float64 {
+	res := make([]float64, size)
+	for i := range res {
+		if rand.Float64() < density {
+			res[i] = rand.Float64()
+		}
+	}
+	return res
+}
+
+func vectorsEqual(v1, v2 Vector) bool {
+	if len(v1.Indices) != len(v2.Indices) || len(v1.Values) != len(v2.Values) {
+		return false
+	}
+	for i, idx := range v1.Indices {
+		if idx != v2.Indices[i] || v1.Values[i] != v2.Values[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -3,9 +3,10 @@ package svm
 import (
 	"encoding/json"
 	"math"
+	"sync"
 
 	"github.com/unixpickle/num-analysis/kahan"
-	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/whichlang/sparse"
 	"github.com/unixpickle/whichlang/tokens"
 )
 
@@ -23,18 +24,42 @@ type BinaryClassifier struct {
 	Threshold float64
 }
 
-// Classifier uses one-against-all SVMs to
-// classify source files.
+// output computes the binary classifier's output
+// given the kernel products of a sample with
+// every support vector.
+func (b *BinaryClassifier) output(products []float64) float64 {
+	productSum := kahan.NewSummer64()
+	for i, vecIdx := range b.SupportVectors {
+		productSum.Add(products[vecIdx] * b.Weights[i])
+	}
+	productSum.Add(-b.Threshold)
+	return productSum.Sum()
+}
+
+// Classifier uses SVMs to classify source files.
 type Classifier struct {
 	Keywords []string
 	Kernel   *Kernel
 
-	SampleVectors []linalg.Vector
+	// SampleVectors stores the support vectors,
+	// which are shared by all the binary classifiers.
+	SampleVectors []sparse.Vector
+
+	// Multiclass specifies which of Classifiers and
+	// PairClassifiers is used.
+	Multiclass MulticlassMode
 
 	// Classifiers maps each language to its
 	// corresponding one-against-all binary
 	// classifier.
 	Classifiers map[string]BinaryClassifier
+
+	// PairClassifiers stores a one-against-one
+	// binary classifier for each pair of languages.
+	PairClassifiers []PairClassifier `json:",omitempty"`
+
+	keywordIndicesOnce sync.Once
+	keywordIndices     map[string]int
 }
 
 func DecodeClassifier(d []byte) (*Classifier, error) {
@@ -48,17 +73,17 @@ func DecodeClassifier(d []byte) (*Classifier, error) {
 func (c *Classifier) Classify(sample tokens.Freqs) string {
 	products := c.sampleProducts(sample)
 
+	if c.Multiclass == OneAgainstOne {
+		return c.classifyPairs(products)
+	}
+
 	var bestLanguage string
 	bestClassification := math.Inf(-1)
 
 	for lang, classifier := range c.Classifiers {
-		productSum := kahan.NewSummer64()
-		for i, vecIdx := range classifier.SupportVectors {
-			productSum.Add(products[vecIdx] * classifier.Weights[i])
-		}
-		productSum.Add(-classifier.Threshold)
-		if productSum.Sum() > bestClassification {
-			bestClassification = productSum.Sum()
+		output := classifier.output(products)
+		if output > bestClassification {
+			bestClassification = output
 			bestLanguage = lang
 		}
 	}
@@ -72,6 +97,19 @@ func (c *Classifier) Encode() []byte {
 }
 
 func (c *Classifier) Languages() []string {
+	if c.Multiclass == OneAgainstOne {
+		seen := map[string]bool{}
+		for _, pair := range c.PairClassifiers {
+			seen[pair.Positive] = true
+			seen[pair.Negative] = true
+		}
+		res := make([]string, 0, len(seen))
+		for lang := range seen {
+			res = append(res, lang)
+		}
+		return res
+	}
+
 	res := make([]string, 0, len(c.Classifiers))
 	for lang := range c.Classifiers {
 		res = append(res, lang)
@@ -79,6 +117,30 @@ func (c *Classifier) Languages() []string {
 	return res
 }
 
+func (c *Classifier) classifyPairs(products []float64) string {
+	votes := map[string]int{}
+	margins := map[string]float64{}
+	for _, pair := range c.PairClassifiers {
+		output := pair.output(products)
+		if output > 0 {
+			votes[pair.Positive]++
+		} else {
+			votes[pair.Negative]++
+		}
+		margins[pair.Positive] += output
+		margins[pair.Negative] -= output
+	}
+
+	var bestLanguage string
+	for lang, count := range votes {
+		if bestLanguage == "" || count > votes[bestLanguage] ||
+			(count == votes[bestLanguage] && margins[lang] > margins[bestLanguage]) {
+			bestLanguage = lang
+		}
+	}
+	return bestLanguage
+}
+
 func (c *Classifier) sampleProducts(sample tokens.Freqs) []float64 {
 	vec := c.sampleVector(sample)
 	res := make([]float64, len(c.SampleVectors))
@@ -88,10 +150,12 @@ func (c *Classifier) sampleProducts(sample tokens.Freqs) []float64 {
 	return res
 }
 
-func (c *Classifier) sampleVector(sample tokens.Freqs) linalg.Vector {
-	vec := make(linalg.Vector, len(c.Keywords))
-	for i, keyword := range c.Keywords {
-		vec[i] = sample[keyword]
-	}
-	return vec
+func (c *Classifier) sampleVector(sample tokens.Freqs) sparse.Vector {
+	c.keywordIndicesOnce.Do(func() {
+		c.keywordIndices = make(map[string]int, len(c.Keywords))
+		for i, keyword := range c.Keywords {
+			c.keywordIndices[keyword] = i
+		}
+	})
+	return sparse.FreqsVector(c.keywordIndices, sample)
 }
@@ -5,7 +5,7 @@ import (
 	"math"
 	"strconv"
 
-	"github.com/unixpickle/num-analysis/linalg"
+	"github.com/unixpickle/whichlang/sparse"
 )
 
 type KernelType int
@@ -22,6 +22,15 @@ const (
 	// RadialBasisKernel computes inner products as
 	// exp(-k1*||x-y||^2), where k1 is a parameter.
 	RadialBasisKernel
+
+	// HistogramIntersectionKernel computes inner
+	// products as sum(min(x_i, y_i)), with no
+	// parameters.
+	HistogramIntersectionKernel
+
+	// ChiSquareKernel computes inner products as
+	// sum(2*x_i*y_i/(x_i+y_i)), with no parameters.
+	ChiSquareKernel
 )
 
 // A Kernel computes inner products of vectors
@@ -33,7 +42,7 @@ type Kernel struct {
 
 // Product returns the product of two vectors
 // under this kernel.
-func (k *Kernel) Product(v1, v2 linalg.Vector) float64 {
+func (k *Kernel) Product(v1, v2 sparse.Vector) float64 {
 	switch k.Type {
 	case LinearKernel:
 		return v1.Dot(v2)
@@ -46,8 +55,22 @@ func (k *Kernel) Product(v1, v2 linalg.Vector) float64 {
 		if len(k.Params) != 1 {
 			panic("expected one parameter for radial basis kernel")
 		}
-		diff := v1.Copy().Scale(-1).Add(v2)
-		return math.Exp(-k.Params[0] * diff.Dot(diff))
+		diffMag := v1.Dot(v1) + v2.Dot(v2) - 2*v1.Dot(v2)
+		return math.Exp(-k.Params[0] * math.Max(0, diffMag))
+	case HistogramIntersectionKernel:
+		var sum float64
+		v1.ForUnion(v2, func(x, y float64) {
+			sum += math.Min(x, y)
+		})
+		return sum
+	case ChiSquareKernel:
+		var sum float64
+		v1.ForUnion(v2, func(x, y float64) {
+			if x+y != 0 {
+				sum += 2 * x * y / (x + y)
+			}
+		})
+		return sum
 	default:
 		panic("unknown kernel type: " + strconv.Itoa(int(k.Type)))
 	}
@@ -69,6 +92,10 @@ func (k *Kernel) String() string {
 			panic("expected one parameter for radial basis kernel")
 		}
 		return fmt.Sprintf("exp(-%f*(x*y)^2)", k.Params[0])
+	case HistogramIntersectionKernel:
+		return "sum(min(x_i, y_i))"
+	case ChiSquareKernel:
+		return "sum(2*x_i*y_i/(x_i+y_i))"
 	default:
 		panic("unknown kernel type: " + strconv.Itoa(int(k.Type)))
 	}
@@ -0,0 +1,125 @@
+package svm
+
+import (
+	"log"
+	"math"
+	"math/rand"
+
+	"github.com/unixpickle/whichlang/sparse"
+)
+
+const (
+	linearMaxIterations = 1000
+	linearTolerance     = 1e-2
+)
+
+// trainLinear trains linear SVMs using dual
+// coordinate descent (Hsieh et al., 2008) directly
+// on sparse vectors.
+//
+// The resulting Classifier stores one weight vector
+// per binary classifier, the same compact form
+// produced by the svm-shrink command.
+func trainLinear(toks []string, samples map[string][]sparse.Vector, kernel *Kernel,
+	tradeoff float64, p *TrainerParams) *Classifier {
+	classifier := &Classifier{
+		Keywords:   toks,
+		Kernel:     kernel,
+		Multiclass: p.Multiclass,
+	}
+
+	tasks := binaryTasks(samples, p.Multiclass)
+	weights := make([][]float64, len(tasks))
+	biases := make([]float64, len(tasks))
+	forEachTask(tasks, func(idx int, t binaryTask) {
+		if p.Verbose {
+			log.Println("Training classifier for:", t)
+		}
+		var vecs []sparse.Vector
+		var labels []float64
+		for lang, langVecs := range samples {
+			var label float64
+			if lang == t.Positive {
+				label = 1
+			} else if t.IsNegative(lang) {
+				label = -1
+			} else {
+				continue
+			}
+			for _, vec := range langVecs {
+				vecs = append(vecs, vec)
+				labels = append(labels, label)
+			}
+		}
+
+		// The solver minimizes 0.5*||w||^2 + C*sum(hinge),
+		// which is equivalent to minimizing
+		// 0.5*Tradeoff*||w||^2 + mean(hinge).
+		c := 1 / (tradeoff * float64(len(vecs)))
+
+		weights[idx], biases[idx] = dualCoordinateDescent(len(toks), vecs, labels, c)
+	})
+
+	for i, t := range tasks {
+		classifier.addBinary(t, BinaryClassifier{
+			SupportVectors: []int{len(classifier.SampleVectors)},
+			Weights:        []float64{1},
+			Threshold:      -biases[i],
+		})
+		vec := sparse.NewVector(weights[i])
+		classifier.SampleVectors = append(classifier.SampleVectors, vec)
+	}
+
+	return classifier
+}
+
+// dualCoordinateDescent solves the dual of an
+// L1-loss linear SVM with box constraint c.
+// The bias is learned by augmenting every vector
+// with a constant component of 1.
+func dualCoordinateDescent(dim int, vecs []sparse.Vector, labels []float64,
+	c float64) (weights []float64, bias float64) {
+	weights = make([]float64, dim)
+	alphas := make([]float64, len(vecs))
+
+	diagonal := make([]float64, len(vecs))
+	for i, vec := range vecs {
+		diagonal[i] = vec.Dot(vec) + 1
+	}
+
+	for iter := 0; iter < linearMaxIterations; iter++ {
+		maxGrad := math.Inf(-1)
+		minGrad := math.Inf(1)
+
+		for _, i := range rand.Perm(len(vecs)) {
+			y := labels[i]
+			grad := y*(vecs[i].DotDense(weights)+bias) - 1
+
+			projGrad := grad
+			if alphas[i] == 0 {
+				projGrad = math.Min(grad, 0)
+			} else if alphas[i] == c {
+				projGrad = math.Max(grad, 0)
+			}
+			maxGrad = math.Max(maxGrad, projGrad)
+			minGrad = math.Min(minGrad, projGrad)
+			if projGrad == 0 {
+				continue
+			}
+
+			oldAlpha := alphas[i]
+			alphas[i] = math.Min(math.Max(oldAlpha-grad/diagonal[i], 0), c)
+			delta := (alphas[i] - oldAlpha) * y
+			for j, idx := range vecs[i].Indices {
+				weights[idx] += delta * vecs[i].Values[j]
+			}
+			bias += delta
+		}
+
+		if maxGrad-minGrad < linearTolerance {
+			break
+		}
+	}
+
+	return
+}
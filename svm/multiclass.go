@@ -0,0 +1,130 @@
+package svm
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/unixpickle/whichlang/sparse"
+)
+
+// A MulticlassMode specifies how a Classifier
+// combines binary SVMs to choose between several
+// languages.
+type MulticlassMode int
+
+const (
+	// OneAgainstAll trains one binary classifier per
+	// language, separating it from every other
+	// language, and picks the language whose
+	// classifier gives the highest output.
+	OneAgainstAll MulticlassMode = iota
+
+	// OneAgainstOne trains one binary classifier for
+	// each pair of languages, and picks the language
+	// which wins the most pairwise votes.
+	// Ties are broken by the sum of the classifiers'
+	// outputs.
+	OneAgainstOne
+)
+
+// A PairClassifier is a one-against-one binary
+// classifier, which gives positive outputs for
+// the Positive language and negative outputs
+// for the Negative language.
+type PairClassifier struct {
+	Positive string
+	Negative string
+
+	BinaryClassifier
+}
+
+// A binaryTask describes one of the binary problems
+// solved to train a multiclass Classifier.
+type binaryTask struct {
+	Positive string
+
+	// Negative is the language for the negative
+	// samples, or "" if every language besides
+	// Positive is negative.
+	Negative string
+}
+
+// binaryTasks returns the binary problems needed to
+// train a Classifier in the given mode.
+func binaryTasks(samples map[string][]sparse.Vector, mode MulticlassMode) []binaryTask {
+	langs := make([]string, 0, len(samples))
+	for lang := range samples {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	var res []binaryTask
+	for i, lang := range langs {
+		if mode == OneAgainstAll {
+			res = append(res, binaryTask{Positive: lang})
+			continue
+		}
+		for _, other := range langs[i+1:] {
+			res = append(res, binaryTask{Positive: lang, Negative: other})
+		}
+	}
+	return res
+}
+
+// IsNegative returns whether or not a language is
+// used for negative samples in the task.
+func (b binaryTask) IsNegative(lang string) bool {
+	if b.Negative == "" {
+		return lang != b.Positive
+	}
+	return lang == b.Negative
+}
+
+// String returns a human-readable description of
+// the task for logging.
+func (b binaryTask) String() string {
+	if b.Negative == "" {
+		return b.Positive
+	}
+	return b.Positive + " vs. " + b.Negative
+}
+
+// forEachTask calls f concurrently for every task,
+// using up to GOMAXPROCS goroutines.
+func forEachTask(tasks []binaryTask, f func(idx int, t binaryTask)) {
+	indices := make(chan int, len(tasks))
+	for i := range tasks {
+		indices <- i
+	}
+	close(indices)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				f(idx, tasks[idx])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// addBinary adds the solution to a binary task
+// to the classifier.
+func (c *Classifier) addBinary(t binaryTask, b BinaryClassifier) {
+	if t.Negative == "" {
+		if c.Classifiers == nil {
+			c.Classifiers = map[string]BinaryClassifier{}
+		}
+		c.Classifiers[t.Positive] = b
+	} else {
+		c.PairClassifiers = append(c.PairClassifiers, PairClassifier{
+			Positive:         t.Positive,
+			Negative:         t.Negative,
+			BinaryClassifier: b,
+		})
+	}
+}
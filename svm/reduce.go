@@ -0,0 +1,176 @@
+package svm
+
+import (
+	"math"
+	"sort"
+
+	"github.com/unixpickle/whichlang/sparse"
+)
+
+// reduceRidge is added to the diagonal of the
+// kernel matrix (scaled by the mean diagonal entry)
+// to keep the reduced-set projection well-posed
+// when support vectors are nearly dependent.
+const reduceRidge = 1e-8
+
+// Reduce approximates c with a classifier that has
+// at most budget support vectors.
+//
+// The support vectors with the greatest total weight
+// across every binary classifier are kept, and each
+// binary classifier's weights are re-fit to best
+// approximate its original decision function in the
+// kernel's feature space (a reduced-set method).
+//
+// If c already fits within the budget, it is
+// returned as is.
+func (c *Classifier) Reduce(budget int) *Classifier {
+	if budget >= len(c.SampleVectors) {
+		return c
+	}
+
+	kept := c.importantVectors(budget)
+	keptVecs := make([]sparse.Vector, len(kept))
+	for i, idx := range kept {
+		keptVecs[i] = c.SampleVectors[idx]
+	}
+
+	gram := make([][]float64, len(kept))
+	var diagSum float64
+	for i, v1 := range keptVecs {
+		gram[i] = make([]float64, len(kept))
+		for j, v2 := range keptVecs[:i+1] {
+			gram[i][j] = c.Kernel.Product(v1, v2)
+			gram[j][i] = gram[i][j]
+		}
+		diagSum += gram[i][i]
+	}
+	ridge := reduceRidge * math.Max(diagSum/float64(len(kept)), 1)
+	for i := range gram {
+		gram[i][i] += ridge
+	}
+	factor := choleskyFactor(gram)
+
+	// crossProducts[i][j] is the kernel product of the
+	// i-th kept vector and the j-th original vector.
+	crossProducts := make([][]float64, len(kept))
+	for i, v1 := range keptVecs {
+		crossProducts[i] = make([]float64, len(c.SampleVectors))
+		for j, v2 := range c.SampleVectors {
+			crossProducts[i][j] = c.Kernel.Product(v1, v2)
+		}
+	}
+
+	project := func(b BinaryClassifier) BinaryClassifier {
+		rhs := make([]float64, len(kept))
+		for i := range rhs {
+			for j, vecIdx := range b.SupportVectors {
+				rhs[i] += crossProducts[i][vecIdx] * b.Weights[j]
+			}
+		}
+		res := BinaryClassifier{
+			SupportVectors: make([]int, len(kept)),
+			Weights:        choleskySolve(factor, rhs),
+			Threshold:      b.Threshold,
+		}
+		for i := range res.SupportVectors {
+			res.SupportVectors[i] = i
+		}
+		return res
+	}
+
+	res := &Classifier{
+		Keywords:      c.Keywords,
+		Kernel:        c.Kernel,
+		SampleVectors: keptVecs,
+		Multiclass:    c.Multiclass,
+	}
+	if c.Classifiers != nil {
+		res.Classifiers = map[string]BinaryClassifier{}
+	}
+	for lang, b := range c.Classifiers {
+		res.Classifiers[lang] = project(b)
+	}
+	for _, pair := range c.PairClassifiers {
+		pair.BinaryClassifier = project(pair.BinaryClassifier)
+		res.PairClassifiers = append(res.PairClassifiers, pair)
+	}
+	return res
+}
+
+// importantVectors returns the indices of the count
+// support vectors with the largest total weight,
+// where each weight is scaled by the vector's norm
+// in feature space.
+func (c *Classifier) importantVectors(count int) []int {
+	importance := make([]float64, len(c.SampleVectors))
+	addWeights := func(b BinaryClassifier) {
+		for i, vecIdx := range b.SupportVectors {
+			importance[vecIdx] += math.Abs(b.Weights[i])
+		}
+	}
+	for _, b := range c.Classifiers {
+		addWeights(b)
+	}
+	for _, pair := range c.PairClassifiers {
+		addWeights(pair.BinaryClassifier)
+	}
+	for i, vec := range c.SampleVectors {
+		importance[i] *= math.Sqrt(math.Max(0, c.Kernel.Product(vec, vec)))
+	}
+
+	indices := make([]int, len(importance))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return importance[indices[i]] > importance[indices[j]]
+	})
+	res := indices[:count]
+	sort.Ints(res)
+	return res
+}
+
+// choleskyFactor computes the lower-triangular
+// Cholesky factor of a symmetric positive-definite
+// matrix.
+func choleskyFactor(m [][]float64) [][]float64 {
+	res := make([][]float64, len(m))
+	for i := range m {
+		res[i] = make([]float64, i+1)
+		for j := 0; j <= i; j++ {
+			sum := m[i][j]
+			for k := 0; k < j; k++ {
+				sum -= res[i][k] * res[j][k]
+			}
+			if i == j {
+				res[i][i] = math.Sqrt(math.Max(sum, 1e-300))
+			} else {
+				res[i][j] = sum / res[j][j]
+			}
+		}
+	}
+	return res
+}
+
+// choleskySolve solves L*L^T*x = b for x, given the
+// Cholesky factor L.
+func choleskySolve(l [][]float64, b []float64) []float64 {
+	y := make([]float64, len(b))
+	for i := range b {
+		sum := b[i]
+		for k := 0; k < i; k++ {
+			sum -= l[i][k] * y[k]
+		}
+		y[i] = sum / l[i][i]
+	}
+	x := make([]float64, len(b))
+	for i := len(b) - 1; i >= 0; i-- {
+		sum := y[i]
+		for k := i + 1; k < len(b); k++ {
+			sum -= l[k][i] * x[k]
+		}
+		x[i] = sum / l[i][i]
+	}
+	return x
+}
@@ -0,0 +1,148 @@
+package svm
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/unixpickle/whichlang/sparse"
+)
+
+func TestReduceWithinBudget(t *testing.T) {
+	kernel := &Kernel{Type: RadialBasisKernel, Params: []float64{1}}
+	c := randomClassifier(kernel, randomVectors(40, 10), 25)
+
+	// The unused sample vectors are dropped, but every
+	// support vector fits in the budget.
+	reduced := c.Reduce(30)
+	if len(reduced.SampleVectors) != 30 {
+		t.Fatalf("expected 30 vectors but got %d", len(reduced.SampleVectors))
+	}
+	for _, query := range randomVectors(20, 10) {
+		expected := decisionValues(c, query)
+		actual := decisionValues(reduced, query)
+		for lang, x := range expected {
+			if math.Abs(actual[lang]-x) > 1e-5 {
+				t.Errorf("%s: expected %f but got %f", lang, x, actual[lang])
+			}
+		}
+	}
+
+	if c.Reduce(len(c.SampleVectors)) != c {
+		t.Error("expected classifier within budget to be unchanged")
+	}
+}
+
+func TestReduceDegenerate(t *testing.T) {
+	base := randomVectors(10, 5)
+	var duplicates, nearDuplicates, zeros []sparse.Vector
+	for _, v := range base {
+		duplicates = append(duplicates, v, v)
+		nearly := v.Copy()
+		if len(nearly.Values) > 0 {
+			nearly.Values[0] += 1e-12
+		}
+		nearDuplicates = append(nearDuplicates, v, nearly)
+		zeros = append(zeros, sparse.Vector{})
+	}
+	vectorSets := map[string][]sparse.Vector{
+		"duplicates":      duplicates,
+		"near-duplicates": nearDuplicates,
+		"zeros":           zeros,
+	}
+	kernels := []*Kernel{
+		{Type: LinearKernel},
+		{Type: RadialBasisKernel, Params: []float64{1}},
+		{Type: ChiSquareKernel},
+	}
+	for name, vecs := range vectorSets {
+		for _, kernel := range kernels {
+			c := randomClassifier(kernel, vecs, len(vecs))
+			for _, budget := range []int{0, 1, 5, len(vecs) - 1} {
+				reduced := c.Reduce(budget)
+				if len(reduced.SampleVectors) != budget {
+					t.Errorf("%s %s: budget %d kept %d vectors", name, kernel, budget,
+						len(reduced.SampleVectors))
+				}
+				for lang, b := range reduced.Classifiers {
+					for _, w := range b.Weights {
+						if math.IsNaN(w) || math.IsInf(w, 0) {
+							t.Errorf("%s %s: budget %d gave %s weight %f", name, kernel,
+								budget, lang, w)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCholeskySolve(t *testing.T) {
+	m := [][]float64{
+		{4, 2, 0.5},
+		{2, 5, 1},
+		{0.5, 1, 3},
+	}
+	expected := []float64{1, -2, 3}
+	b := make([]float64, len(m))
+	for i, row := range m {
+		for j, x := range row {
+			b[i] += x * expected[j]
+		}
+	}
+	actual := choleskySolve(choleskyFactor(m), b)
+	for i, x := range expected {
+		if math.Abs(actual[i]-x) > 1e-10 {
+			t.Errorf("component %d: expected %f but got %f", i, x, actual[i])
+		}
+	}
+}
+
+// randomClassifier creates a one-against-all
+// classifier for three languages, each of which
+// uses some of the first used vectors.
+func randomClassifier(k *Kernel, vecs []sparse.Vector, used int) *Classifier {
+	res := &Classifier{
+		Kernel:        k,
+		SampleVectors: vecs,
+		Classifiers:   map[string]BinaryClassifier{},
+	}
+	for _, lang := range []string{"A", "B", "C"} {
+		var b BinaryClassifier
+		for i := 0; i < used; i++ {
+			if i == 0 || rand.Intn(2) == 0 {
+				b.SupportVectors = append(b.SupportVectors, i)
+				b.Weights = append(b.Weights, rand.NormFloat64())
+			}
+		}
+		b.Threshold = rand.NormFloat64()
+		res.Classifiers[lang] = b
+	}
+	return res
+}
+
+func randomVectors(count, size int) []sparse.Vector {
+	res := make([]sparse.Vector, count)
+	for i := range res {
+		dense := make([]float64, size)
+		for j := range dense {
+			if rand.Intn(2) == 0 {
+				dense[j] = rand.Float64()
+			}
+		}
+		res[i] = sparse.NewVector(dense)
+	}
+	return res
+}
+
+func decisionValues(c *Classifier, v sparse.Vector) map[string]float64 {
+	products := make([]float64, len(c.SampleVectors))
+	for i, s := range c.SampleVectors {
+		products[i] = c.Kernel.Product(s, v)
+	}
+	res := map[string]float64{}
+	for lang, b := range c.Classifiers {
+		res[lang] = b.output(products)
+	}
+	return res
+}
@@ -3,10 +3,11 @@ package svm
 import (
 	"log"
 	"math/rand"
+	"sync"
 	"time"
 
-	"github.com/unixpickle/num-analysis/linalg"
 	"github.com/unixpickle/weakai/svm"
+	"github.com/unixpickle/whichlang/sparse"
 	"github.com/unixpickle/whichlang/tokens"
 )
 
@@ -20,65 +21,163 @@ func Train(data map[string][]tokens.Freqs) *Classifier {
 	return TrainParams(data, params)
 }
 
+// TrainParams trains a Classifier on the data, using
+// cross-validation to choose the kernel and tradeoff
+// from the candidates in p.
+// The chosen configuration is retrained on all of
+// the data to produce the final Classifier.
 func TrainParams(data map[string][]tokens.Freqs, p *TrainerParams) *Classifier {
-	crossFreqs, trainingFreqs := partitionSamples(data, p.CrossValidation)
-	tokens, samples := vectorizeSamples(trainingFreqs)
+	var bestKernel *Kernel
+	var bestTradeoff float64
 
-	solver := svm.GradientDescentSolver{
-		Timeout:  farAwayTimeout,
-		Tradeoff: p.Tradeoff,
+	if len(p.Kernels)*len(p.Tradeoffs) == 1 {
+		bestKernel = p.Kernels[0]
+		bestTradeoff = p.Tradeoffs[0]
+	} else {
+		folds := makeFolds(data, p)
+		bestValidationScore := -1.0
+		for _, kernel := range p.Kernels {
+			for _, tradeoff := range p.Tradeoffs {
+				if p.Verbose {
+					log.Printf("Trying kernel %s with tradeoff %e", kernel, tradeoff)
+				}
+				score := crossValidate(folds, kernel, tradeoff, p)
+				if p.Verbose {
+					log.Printf("Results: cross=%f", score)
+				}
+				if score > bestValidationScore {
+					bestValidationScore = score
+					bestKernel = kernel
+					bestTradeoff = tradeoff
+				}
+			}
+		}
 	}
 
-	var bestClassifier *Classifier
-	var bestValidationScore float64
+	if p.Verbose {
+		log.Printf("Training final classifier: kernel %s with tradeoff %e", bestKernel,
+			bestTradeoff)
+	}
+	toks, samples := vectorizeSamples(data)
+	classifier := trainClassifier(toks, samples, bestKernel, bestTradeoff, p)
+	if p.Verbose {
+		log.Printf("Results: training=%f support=%d/%d", correctFraction(classifier, data),
+			len(classifier.SampleVectors), countSamples(samples))
+	}
+	return classifier
+}
 
-	for _, kernel := range p.Kernels {
-		if p.Verbose {
-			log.Println("Trying kernel:", kernel)
-		}
-		solverKernel := cachedKernel(kernel)
-		classifier := &Classifier{
-			Keywords:    tokens,
-			Kernel:      kernel,
-			Classifiers: map[string]BinaryClassifier{},
-		}
+// A fold is one split of the data used for
+// cross-validation.
+type fold struct {
+	Cross    map[string][]tokens.Freqs
+	Training map[string][]tokens.Freqs
+}
 
-		usedSamples := map[int]linalg.Vector{}
-		for lang := range samples {
-			if p.Verbose {
-				log.Println("Training classifier for language:", lang)
-			}
-			problem := svmProblem(samples, lang, solverKernel)
-			solution := solver.Solve(problem)
-			binClass := BinaryClassifier{
-				SupportVectors: make([]int, len(solution.SupportVectors)),
-				Weights:        make([]float64, len(solution.Coefficients)),
-				Threshold:      -solution.Threshold,
-			}
-			copy(binClass.Weights, solution.Coefficients)
-			for i, v := range solution.SupportVectors {
-				// v.UserInfo will be turned into a support
-				// vector index by makeSampleVectorList().
-				binClass.SupportVectors[i] = v.UserInfo
-				usedSamples[v.UserInfo] = linalg.Vector(v.V)
+// makeFolds splits the data into p.Folds folds,
+// or into a single random split if p.Folds is
+// less than 2.
+func makeFolds(data map[string][]tokens.Freqs, p *TrainerParams) []fold {
+	if p.Folds < 2 {
+		cross, training := partitionSamples(data, p.CrossValidation)
+		return []fold{{Cross: cross, Training: training}}
+	}
+
+	res := make([]fold, p.Folds)
+	for i := range res {
+		res[i] = fold{
+			Cross:    map[string][]tokens.Freqs{},
+			Training: map[string][]tokens.Freqs{},
+		}
+	}
+	for lang, samples := range data {
+		for i, x := range rand.Perm(len(samples)) {
+			foldIdx := i % p.Folds
+			for j, f := range res {
+				if j == foldIdx {
+					f.Cross[lang] = append(f.Cross[lang], samples[x])
+				} else {
+					f.Training[lang] = append(f.Training[lang], samples[x])
+				}
 			}
-			classifier.Classifiers[lang] = binClass
 		}
+	}
+	return res
+}
+
+// crossValidate computes the mean validation score
+// of a configuration across every fold.
+func crossValidate(folds []fold, kernel *Kernel, tradeoff float64, p *TrainerParams) float64 {
+	var scoreSum float64
+	for _, f := range folds {
+		toks, samples := vectorizeSamples(f.Training)
+		classifier := trainClassifier(toks, samples, kernel, tradeoff, p)
+		scoreSum += correctFraction(classifier, f.Cross)
+	}
+	return scoreSum / float64(len(folds))
+}
+
+func trainClassifier(toks []string, samples map[string][]sparse.Vector, kernel *Kernel,
+	tradeoff float64, p *TrainerParams) *Classifier {
+	if kernel.Type == LinearKernel && p.Solver == DualCoordinateSolver {
+		return trainLinear(toks, samples, kernel, tradeoff, p)
+	}
+	return trainGradientDescent(toks, samples, kernel, tradeoff, p)
+}
+
+// trainGradientDescent trains a classifier using
+// weakai's gradient descent solver.
+//
+// Binary problems are solved concurrently, each
+// with its own kernel cache.
+func trainGradientDescent(toks []string, samples map[string][]sparse.Vector, kernel *Kernel,
+	tradeoff float64, p *TrainerParams) *Classifier {
+	solver := svm.GradientDescentSolver{
+		Timeout:  farAwayTimeout,
+		Tradeoff: tradeoff,
+	}
 
-		makeSampleVectorList(classifier, usedSamples)
+	denseSamples, sparseVecs := solverSamples(len(toks), samples)
+	classifier := &Classifier{
+		Keywords:   toks,
+		Kernel:     kernel,
+		Multiclass: p.Multiclass,
+	}
 
-		score := correctFraction(classifier, crossFreqs)
+	tasks := binaryTasks(samples, p.Multiclass)
+	solutions := make([]BinaryClassifier, len(tasks))
+
+	var lock sync.Mutex
+	usedSamples := map[int]sparse.Vector{}
+	forEachTask(tasks, func(idx int, t binaryTask) {
 		if p.Verbose {
-			trainingScore := correctFraction(classifier, trainingFreqs)
-			log.Printf("Results: cross=%f training=%f support=%d/%d", score,
-				trainingScore, len(classifier.SampleVectors), countSamples(samples))
+			log.Println("Training classifier for:", t)
 		}
-		if score > bestValidationScore || bestClassifier == nil {
-			bestClassifier = classifier
+		problem := svmProblem(denseSamples, t, cachedKernel(kernel, sparseVecs))
+		solution := solver.Solve(problem)
+		binClass := BinaryClassifier{
+			SupportVectors: make([]int, len(solution.SupportVectors)),
+			Weights:        make([]float64, len(solution.Coefficients)),
+			Threshold:      -solution.Threshold,
 		}
-	}
+		copy(binClass.Weights, solution.Coefficients)
+
+		lock.Lock()
+		defer lock.Unlock()
+		for i, v := range solution.SupportVectors {
+			// v.UserInfo will be turned into a support
+			// vector index by makeSampleVectorList().
+			binClass.SupportVectors[i] = v.UserInfo
+			usedSamples[v.UserInfo] = sparseVecs[v.UserInfo]
+		}
+		solutions[idx] = binClass
+	})
 
-	return bestClassifier
+	for i, t := range tasks {
+		classifier.addBinary(t, solutions[i])
+	}
+	makeSampleVectorList(classifier, usedSamples)
+	return classifier
 }
 
 func partitionSamples(data map[string][]tokens.Freqs, crossFrac float64) (cross,
@@ -101,7 +200,7 @@ func partitionSamples(data map[string][]tokens.Freqs, crossFrac float64) (cross,
 	return
 }
 
-func vectorizeSamples(data map[string][]tokens.Freqs) ([]string, map[string][]svm.Sample) {
+func vectorizeSamples(data map[string][]tokens.Freqs) ([]string, map[string][]sparse.Vector) {
 	seenToks := map[string]bool{}
 	for _, samples := range data {
 		for _, sample := range samples {
@@ -111,25 +210,17 @@ func vectorizeSamples(data map[string][]tokens.Freqs) ([]string, map[string][]sv
 		}
 	}
 	toks := make([]string, 0, len(seenToks))
+	tokIndices := make(map[string]int, len(seenToks))
 	for tok := range seenToks {
+		tokIndices[tok] = len(toks)
 		toks = append(toks, tok)
 	}
 
-	sampleMap := map[string][]svm.Sample{}
-	sampleID := 1
+	sampleMap := map[string][]sparse.Vector{}
 	for lang, samples := range data {
-		vecSamples := make([]svm.Sample, 0, len(samples))
+		vecSamples := make([]sparse.Vector, 0, len(samples))
 		for _, sample := range samples {
-			vec := make([]float64, len(toks))
-			for i, tok := range toks {
-				vec[i] = sample[tok]
-			}
-			svmSample := svm.Sample{
-				V:        vec,
-				UserInfo: sampleID,
-			}
-			sampleID++
-			vecSamples = append(vecSamples, svmSample)
+			vecSamples = append(vecSamples, sparse.FreqsVector(tokIndices, sample))
 		}
 		sampleMap[lang] = vecSamples
 	}
@@ -137,7 +228,30 @@ func vectorizeSamples(data map[string][]tokens.Freqs) ([]string, map[string][]sv
 	return toks, sampleMap
 }
 
-func countSamples(s map[string][]svm.Sample) int {
+// solverSamples generates dense samples for the
+// gradient descent solver, along with a mapping
+// from each sample's UserInfo to its sparse vector.
+func solverSamples(numToks int, samples map[string][]sparse.Vector) (map[string][]svm.Sample,
+	map[int]sparse.Vector) {
+	sampleMap := map[string][]svm.Sample{}
+	sparseVecs := map[int]sparse.Vector{}
+	sampleID := 1
+	for lang, vecs := range samples {
+		langSamples := make([]svm.Sample, len(vecs))
+		for i, vec := range vecs {
+			langSamples[i] = svm.Sample{
+				V:        vec.Dense(numToks),
+				UserInfo: sampleID,
+			}
+			sparseVecs[sampleID] = vec
+			sampleID++
+		}
+		sampleMap[lang] = langSamples
+	}
+	return sampleMap, sparseVecs
+}
+
+func countSamples(s map[string][]sparse.Vector) int {
 	var count int
 	for _, samples := range s {
 		count += len(samples)
@@ -145,18 +259,18 @@ func countSamples(s map[string][]svm.Sample) int {
 	return count
 }
 
-func cachedKernel(k *Kernel) svm.Kernel {
+func cachedKernel(k *Kernel, vecs map[int]sparse.Vector) svm.Kernel {
 	return svm.CachedKernel(func(s1, s2 svm.Sample) float64 {
-		return k.Product(linalg.Vector(s1.V), linalg.Vector(s2.V))
+		return k.Product(vecs[s1.UserInfo], vecs[s2.UserInfo])
 	})
 }
 
-func svmProblem(data map[string][]svm.Sample, posLang string, k svm.Kernel) *svm.Problem {
+func svmProblem(data map[string][]svm.Sample, t binaryTask, k svm.Kernel) *svm.Problem {
 	var positives, negatives []svm.Sample
 	for lang, samples := range data {
-		if lang == posLang {
+		if lang == t.Positive {
 			positives = append(positives, samples...)
-		} else {
+		} else if t.IsNegative(lang) {
 			negatives = append(negatives, samples...)
 		}
 	}
@@ -180,7 +294,7 @@ func correctFraction(c *Classifier, data map[string][]tokens.Freqs) float64 {
 	return float64(correct) / float64(total)
 }
 
-func makeSampleVectorList(c *Classifier, used map[int]linalg.Vector) {
+func makeSampleVectorList(c *Classifier, used map[int]sparse.Vector) {
 	userInfoToVecIdx := map[int]int{}
 
 	for userInfo, sample := range used {
@@ -193,4 +307,9 @@ func makeSampleVectorList(c *Classifier, used map[int]linalg.Vector) {
 			binClass.SupportVectors[i] = userInfoToVecIdx[userInfo]
 		}
 	}
+	for _, pair := range c.PairClassifiers {
+		for i, userInfo := range pair.SupportVectors {
+			pair.SupportVectors[i] = userInfoToVecIdx[userInfo]
+		}
+	}
 }
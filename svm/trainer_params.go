@@ -4,17 +4,21 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
 )
 
 const (
-	defaultTradeoff                = 1e-5
 	defaultCrossValidationFraction = 0.3
+	defaultFolds                   = 3
 )
 
 var (
-	defaultRBFParams  = [][]float64{{1e-5}, {1e-4}, {1e-3}, {1e-2}, {1e-1}, {1e0}, {1e1}, {1e2}}
+	defaultTradeoffs = []float64{1e-5, 1e-3, 1e-1}
+	defaultKernels   = []KernelType{LinearKernel, PolynomialKernel, RadialBasisKernel,
+		HistogramIntersectionKernel, ChiSquareKernel}
+	defaultRBFParams  = [][]float64{{1e0}, {1e1}, {1e2}}
 	defaultPolyPowers = []float64{2}
-	defaultPolySums   = []float64{0, 1}
+	defaultPolySums   = []float64{1}
 )
 
 // These environment variables specify
@@ -23,40 +27,94 @@ const (
 	// Set this to "1" to get verbose logs.
 	VerboseEnvVar = "SVM_VERBOSE"
 
-	// You may set this to "linear", "rbf", or
-	// "polynomial".
+	// You may set this to "linear", "rbf",
+	// "polynomial", "intersection", or "chisquare",
+	// or to a comma-separated list of kernels to
+	// search.
+	// By default, or if this is "all", every kernel
+	// is searched.
 	KernelEnvVar = "SVM_KERNEL"
 
 	// The numerical constant used in the
 	// RBF kernel.
+	// By default, 1, 10, and 100 are searched.
 	RBFParamEnvVar = "SVM_RBF_PARAM"
 
 	// The degree parameter for polynomial kernels.
+	// The default is 2.
 	PolyDegreeEnvVar = "SVM_POLY_DEGREE"
 
 	// The summed term (before applying the exponential)
 	// for polynomial kernels.
+	// The default is 1.
 	PolySumEnvVar = "SVM_POLY_SUM"
 
 	// The tradeoff between margin size and hinge loss.
 	// The higher the tradeoff value, the greater the
 	// margin size, but at the expense of correct
 	// classifications.
+	// This may be a comma-separated list of
+	// tradeoffs to search.
+	// By default, 1e-5, 1e-3, and 1e-1 are searched.
 	TradeoffEnvVar = "SVM_TRADEOFF"
 
 	// The fraction (from 0-1) of samples which are
-	// used for cross validation.
+	// used for cross validation when SVM_FOLDS is 1.
 	CrossValidationEnvVar = "SVM_CROSS_VALIDATION"
+
+	// The number of folds for k-fold cross validation.
+	// The default is 3.
+	// If this is 1, a single random split is used,
+	// as specified by SVM_CROSS_VALIDATION.
+	// Every fold multiplies the cost of searching
+	// kernels and tradeoffs.
+	FoldsEnvVar = "SVM_FOLDS"
+
+	// You may set this to "dcd" or "gradient" to
+	// choose the solver for linear kernels.
+	// Non-linear kernels always use "gradient".
+	SolverEnvVar = "SVM_SOLVER"
+
+	// You may set this to "ova" (one-against-all)
+	// or "ovo" (one-against-one).
+	// The default is "ova".
+	MulticlassEnvVar = "SVM_MULTICLASS"
+)
+
+// A SolverType specifies how linear SVMs are
+// trained.
+type SolverType int
+
+const (
+	// DualCoordinateSolver trains linear SVMs with
+	// dual coordinate descent on sparse vectors,
+	// producing one weight vector per language.
+	DualCoordinateSolver SolverType = iota
+
+	// GradientDescentSolver trains SVMs with the
+	// kernelized solver from weakai/svm.
+	GradientDescentSolver
 )
 
 // TrainerParams specifies parameters for the
 // SVM trainer.
 type TrainerParams struct {
-	Verbose  bool
-	Kernels  []*Kernel
-	Tradeoff float64
+	Verbose   bool
+	Kernels   []*Kernel
+	Tradeoffs []float64
 
+	// Folds is the number of folds to use for
+	// cross validation.
+	// If it is less than 2, the trainer uses a
+	// single split, withholding a CrossValidation
+	// fraction of the samples.
+	Folds           int
 	CrossValidation float64
+
+	// Solver is used for linear kernels.
+	Solver SolverType
+
+	Multiclass MulticlassMode
 }
 
 // EnvTrainerParams generates TrainerParams
@@ -69,12 +127,21 @@ func EnvTrainerParams() (*TrainerParams, error) {
 	var res TrainerParams
 	var err error
 
-	if res.Tradeoff, err = envTradeoff(); err != nil {
+	if res.Tradeoffs, err = envTradeoffs(); err != nil {
 		return nil, err
 	}
 	if res.CrossValidation, err = envCrossValidation(); err != nil {
 		return nil, err
 	}
+	if res.Folds, err = envFolds(); err != nil {
+		return nil, err
+	}
+	if res.Solver, err = envSolver(); err != nil {
+		return nil, err
+	}
+	if res.Multiclass, err = envMulticlass(); err != nil {
+		return nil, err
+	}
 	res.Verbose = (os.Getenv(VerboseEnvVar) == "1")
 
 	kernTypes, err := envKernelTypes()
@@ -99,11 +166,19 @@ func EnvTrainerParams() (*TrainerParams, error) {
 	return &res, nil
 }
 
-func envTradeoff() (float64, error) {
+func envTradeoffs() ([]float64, error) {
 	if val := os.Getenv(TradeoffEnvVar); val != "" {
-		return strconv.ParseFloat(val, 64)
+		var res []float64
+		for _, str := range strings.Split(val, ",") {
+			tradeoff, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+			if err != nil {
+				return nil, errors.New("invalid tradeoff: " + str)
+			}
+			res = append(res, tradeoff)
+		}
+		return res, nil
 	} else {
-		return defaultTradeoff, nil
+		return defaultTradeoffs, nil
 	}
 }
 
@@ -115,26 +190,65 @@ func envCrossValidation() (float64, error) {
 	}
 }
 
+func envFolds() (int, error) {
+	if val := os.Getenv(FoldsEnvVar); val != "" {
+		res, err := strconv.Atoi(val)
+		if err != nil || res < 1 {
+			return 0, errors.New("invalid fold count: " + val)
+		}
+		return res, nil
+	} else {
+		return defaultFolds, nil
+	}
+}
+
+func envSolver() (SolverType, error) {
+	switch val := os.Getenv(SolverEnvVar); val {
+	case "", "dcd":
+		return DualCoordinateSolver, nil
+	case "gradient":
+		return GradientDescentSolver, nil
+	default:
+		return 0, errors.New("unknown solver: " + val)
+	}
+}
+
+func envMulticlass() (MulticlassMode, error) {
+	switch val := os.Getenv(MulticlassEnvVar); val {
+	case "", "ova":
+		return OneAgainstAll, nil
+	case "ovo":
+		return OneAgainstOne, nil
+	default:
+		return 0, errors.New("unknown multiclass mode: " + val)
+	}
+}
+
 func envKernelTypes() ([]KernelType, error) {
-	if val := os.Getenv(KernelEnvVar); val != "" {
-		res, ok := map[string]KernelType{
-			"linear":     LinearKernel,
-			"polynomial": PolynomialKernel,
-			"rbf":        RadialBasisKernel,
-		}[val]
+	val := os.Getenv(KernelEnvVar)
+	if val == "" || val == "all" {
+		return defaultKernels, nil
+	}
+	var res []KernelType
+	for _, name := range strings.Split(val, ",") {
+		kernType, ok := map[string]KernelType{
+			"linear":       LinearKernel,
+			"polynomial":   PolynomialKernel,
+			"rbf":          RadialBasisKernel,
+			"intersection": HistogramIntersectionKernel,
+			"chisquare":    ChiSquareKernel,
+		}[strings.TrimSpace(name)]
 		if !ok {
-			return nil, errors.New("unknown kernel: " + val)
-		} else {
-			return []KernelType{res}, nil
+			return nil, errors.New("unknown kernel: " + name)
 		}
-	} else {
-		return []KernelType{LinearKernel, PolynomialKernel, RadialBasisKernel}, nil
+		res = append(res, kernType)
 	}
+	return res, nil
 }
 
 func envKernelParams(t KernelType) ([][]float64, error) {
 	switch t {
-	case LinearKernel:
+	case LinearKernel, HistogramIntersectionKernel, ChiSquareKernel:
 		return [][]float64{{}}, nil
 	case RadialBasisKernel:
 		if val := os.Getenv(RBFParamEnvVar); val != "" {
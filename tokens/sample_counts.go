@@ -13,6 +13,11 @@ import (
 // each sample is represented by a Counts.
 type SampleCounts map[string][]Counts
 
+// SampleSources maps programming languages to
+// the paths of their sample documents, in the
+// same order as the corresponding SampleCounts.
+type SampleSources map[string][]string
+
 // ReadSampleCounts computes token counts
 // for programming language samples in a
 // directory.
@@ -26,29 +31,39 @@ type SampleCounts map[string][]Counts
 // of Counts, where each Counts corresponds to
 // one source file.
 func ReadSampleCounts(sampleDir string) (SampleCounts, error) {
+	res, _, err := ReadSampleSources(sampleDir)
+	return res, err
+}
+
+// ReadSampleSources is like ReadSampleCounts, but
+// it also returns the path of each source file.
+func ReadSampleSources(sampleDir string) (SampleCounts, SampleSources, error) {
 	languages, err := readDirectory(sampleDir, true)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	res := SampleCounts{}
+	sources := SampleSources{}
 	for _, language := range languages {
 		langDir := filepath.Join(sampleDir, language)
 		files, err := readDirectory(langDir, false)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		for _, file := range files {
-			contents, err := ioutil.ReadFile(filepath.Join(langDir, file))
+			path := filepath.Join(langDir, file)
+			contents, err := ioutil.ReadFile(path)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			counts := CountTokens(string(contents))
 			res[language] = append(res[language], counts)
+			sources[language] = append(sources[language], path)
 		}
 	}
 
-	return res, nil
+	return res, sources, nil
 }
 
 // NumTokens returns the number of unique
@@ -0,0 +1,115 @@
+package tokens
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+)
+
+// A SampleReader reads labeled samples one at a
+// time, so that a corpus does not have to fit in
+// memory.
+type SampleReader interface {
+	// ReadSample returns the language and token
+	// frequencies of the next sample.
+	// After the last sample, it returns io.EOF.
+	ReadSample() (lang string, f Freqs, err error)
+}
+
+// A TextReader reads labeled samples one at a
+// time as raw text, for classifiers which do not
+// use tokens.
+type TextReader interface {
+	// ReadText returns the language and contents of
+	// the next sample.
+	// After the last sample, it returns io.EOF.
+	ReadText() (lang, text string, err error)
+}
+
+// A DirSampleReader is a SampleReader and a
+// TextReader which reads source files from a
+// sample directory laid out like the one for
+// ReadSampleCounts.
+//
+// Only the paths of the files are kept in memory.
+// Each file is read when its sample is requested.
+type DirSampleReader struct {
+	langs []string
+	paths []string
+}
+
+// NewDirSampleReader lists the source files in a
+// sample directory.
+// If shuffle is true, the samples are read in a
+// random order, which online learners need to avoid
+// seeing every sample of one language in a row.
+func NewDirSampleReader(sampleDir string, shuffle bool) (*DirSampleReader, error) {
+	languages, err := readDirectory(sampleDir, true)
+	if err != nil {
+		return nil, err
+	}
+	res := &DirSampleReader{}
+	for _, language := range languages {
+		langDir := filepath.Join(sampleDir, language)
+		files, err := readDirectory(langDir, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			res.langs = append(res.langs, language)
+			res.paths = append(res.paths, filepath.Join(langDir, file))
+		}
+	}
+	if shuffle {
+		for i := range res.paths {
+			j := i + rand.Intn(len(res.paths)-i)
+			res.langs[i], res.langs[j] = res.langs[j], res.langs[i]
+			res.paths[i], res.paths[j] = res.paths[j], res.paths[i]
+		}
+	}
+	return res, nil
+}
+
+// ReadSample reads and tokenizes the next file.
+func (d *DirSampleReader) ReadSample() (lang string, f Freqs, err error) {
+	lang, text, err := d.ReadText()
+	if err != nil {
+		return "", nil, err
+	}
+	return lang, CountTokens(text).Freqs(), nil
+}
+
+// ReadText reads the next file.
+func (d *DirSampleReader) ReadText() (lang, text string, err error) {
+	if len(d.paths) == 0 {
+		return "", "", io.EOF
+	}
+	contents, err := ioutil.ReadFile(d.paths[0])
+	if err != nil {
+		return "", "", err
+	}
+	lang = d.langs[0]
+	d.langs = d.langs[1:]
+	d.paths = d.paths[1:]
+	return lang, string(contents), nil
+}
+
+// A SliceTextReader is a TextReader which reads
+// samples from parallel slices of languages and
+// texts, in order.
+type SliceTextReader struct {
+	Langs []string
+	Texts []string
+}
+
+// ReadText returns the next sample and removes it
+// from the slices.
+func (s *SliceTextReader) ReadText() (lang, text string, err error) {
+	if len(s.Langs) == 0 {
+		return "", "", io.EOF
+	}
+	lang, text = s.Langs[0], s.Texts[0]
+	s.Langs, s.Texts = s.Langs[1:], s.Texts[1:]
+	return lang, text, nil
+}
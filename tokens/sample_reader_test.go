@@ -0,0 +1,84 @@
+package tokens
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirSampleReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sample_reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"A/one.txt": "foo bar",
+		"A/two.txt": "foo",
+		"B/one.txt": "baz",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := ReadSampleCounts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, shuffle := range []bool{false, true} {
+		r, err := NewDirSampleReader(dir, shuffle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		langCounts := map[string]int{}
+		for {
+			lang, f, err := r.ReadSample()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			var found bool
+			for _, counts := range expected[lang] {
+				if freqsApproxEqual(counts.Freqs(), f) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("unexpected sample for %s: %v", lang, f)
+			}
+			langCounts[lang]++
+		}
+		if langCounts["A"] != 2 || langCounts["B"] != 1 {
+			t.Errorf("unexpected sample counts: %v", langCounts)
+		}
+	}
+}
+
+func TestSliceTextReader(t *testing.T) {
+	r := &SliceTextReader{
+		Langs: []string{"A", "B"},
+		Texts: []string{"foo", "bar"},
+	}
+	for i, expected := range []string{"A:foo", "B:bar"} {
+		lang, text, err := r.ReadText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := lang + ":" + text; actual != expected {
+			t.Errorf("sample %d: expected %s but got %s", i, expected, actual)
+		}
+	}
+	if _, _, err := r.ReadText(); err != io.EOF {
+		t.Errorf("expected io.EOF but got %v", err)
+	}
+}